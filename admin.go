@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"chirpy/internal/auth"
+	"chirpy/internal/config"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/google/uuid"
+)
+
+// Subscription history events recorded by the admin CLI, alongside the
+// Polka ones
+const (
+	adminEventGranted = "admin.granted"
+	adminEventRevoked = "admin.revoked"
+)
+
+// adminCLI runs "chirpy admin" commands straight against the database. It
+// borrows an apiConfig so commands share the handlers' helpers.
+type adminCLI struct {
+	cfg *apiConfig
+	in  *bufio.Reader
+	out io.Writer
+}
+
+type adminCommand struct {
+	name string
+	args string
+	help string
+	run  func(cli *adminCLI, ctx context.Context, args []string) error
+}
+
+var adminCommands = []adminCommand{
+	{name: "create-user", args: "[-verified] <email>", help: "create a user, reading the password from stdin", run: (*adminCLI).createUser},
+	{name: "reset-password", args: "<user>", help: "set a new password, read from stdin, and revoke every session", run: (*adminCLI).resetPassword},
+	{name: "grant-red", args: "[-days 30] <user>", help: "give a user Chirpy Red for a number of days", run: (*adminCLI).grantRed},
+	{name: "revoke-red", args: "<user>", help: "end a user's subscription immediately", run: (*adminCLI).revokeRed},
+	{name: "revoke-sessions", args: "<user>", help: "revoke every refresh token, including OAuth grants", run: (*adminCLI).revokeSessions},
+	{name: "delete-chirp", args: "<chirpID>", help: "delete a chirp", run: (*adminCLI).deleteChirp},
+	{name: "stats", help: "print instance stats", run: (*adminCLI).stats},
+}
+
+// runAdmin is the "chirpy admin" subcommand. Users can be given by ID or
+// email address.
+func runAdmin(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("chirpy admin", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage: chirpy admin [flags] <command> [args]")
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, c := range adminCommands {
+			fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.help)
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Users can be given by ID or email. Only database.url is required. Flags:")
+		fs.PrintDefaults()
+	}
+	conf, err := config.Load(fs, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || !slices.ContainsFunc(adminCommands, func(c adminCommand) bool { return c.name == fs.Arg(0) }) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if conf.Database.URL == "" {
+		return errors.New("database.url must be set")
+	}
+
+	auth.SetPasswordParams(&argon2id.Params{
+		Memory:      uint32(conf.Passwords.Argon2MemoryKiB),
+		Iterations:  uint32(conf.Passwords.Argon2Iterations),
+		Parallelism: uint8(conf.Passwords.Argon2Parallelism),
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	})
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength: conf.Passwords.MinLength,
+		MaxLength: conf.Passwords.MaxLength,
+	}
+	if path := conf.Passwords.BreachedFile; path != "" {
+		passwordPolicy.Breached, err = auth.LoadBreachedPasswords(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	db, backend, err := openDatabase(conf.Database.URL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	store, queries := newStore(db, backend)
+	cli := &adminCLI{
+		cfg: &apiConfig{
+			store:          store,
+			db:             queries,
+			passwordPolicy: passwordPolicy,
+		},
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+	return cli.run(context.Background(), fs.Args())
+}
+
+// run dispatches a command line, e.g. ["grant-red", "-days", "7", "walt@example.com"]
+func (cli *adminCLI) run(ctx context.Context, args []string) error {
+	for _, c := range adminCommands {
+		if c.name == args[0] {
+			return c.run(cli, ctx, args[1:])
+		}
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// parseArgs parses a command's flags and checks it got exactly one argument
+func parseArgs(fs *flag.FlagSet, args []string, usage string) (string, error) {
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 1 {
+		return "", fmt.Errorf("usage: chirpy admin %s %s", fs.Name(), usage)
+	}
+	return fs.Arg(0), nil
+}
+
+// lookupUser finds a user by ID or email address
+func (cli *adminCLI) lookupUser(ctx context.Context, s string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(s); parseErr == nil {
+		user, err = cli.cfg.store.GetUserByID(ctx, id)
+	} else {
+		user, err = cli.cfg.store.GetUserByEmail(ctx, s)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, fmt.Errorf("couldn't find user %s", s)
+	}
+	return user, err
+}
+
+// readPassword reads a password from the first line of stdin, which keeps
+// it out of shell history and the process list, and checks it against the
+// password policy.
+func (cli *adminCLI) readPassword() (string, error) {
+	line, err := cli.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+
+	err = cli.cfg.passwordPolicy.Validate(password)
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		var messages []string
+		for _, v := range policyErr.Violations {
+			messages = append(messages, v.Message)
+		}
+		return "", fmt.Errorf("password rejected: %s", strings.Join(messages, "; "))
+	}
+	return password, err
+}
+
+func (cli *adminCLI) createUser(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	verified := fs.Bool("verified", false, "mark the email address as verified")
+	arg, err := parseArgs(fs, args, "[-verified] <email>")
+	if err != nil {
+		return err
+	}
+
+	email, err := mailer.NormalizeAddress(arg)
+	if err != nil {
+		return err
+	}
+	password, err := cli.readPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user, err := cli.cfg.store.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s is already registered", email)
+		}
+		return err
+	}
+
+	if *verified {
+		user, err = cli.cfg.store.VerifyUserEmail(ctx, database.VerifyUserEmailParams{
+			ID:    user.ID,
+			Email: user.Email,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(cli.out, "Created user %s (%s)\n", user.ID, user.Email)
+	return nil
+}
+
+func (cli *adminCLI) resetPassword(ctx context.Context, args []string) error {
+	arg, err := parseArgs(flag.NewFlagSet("reset-password", flag.ContinueOnError), args, "<user>")
+	if err != nil {
+		return err
+	}
+	user, err := cli.lookupUser(ctx, arg)
+	if err != nil {
+		return err
+	}
+	password, err := cli.readPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = cli.cfg.store.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return err
+	}
+	// As with a reset by email, sessions opened with the old password end
+	err = cli.cfg.store.RevokeAllRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if cli.cfg.db != nil {
+		err = cli.cfg.db.DeletePasswordResetTokensForUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(cli.out, "Reset password for %s and revoked their sessions\n", user.Email)
+	return nil
+}
+
+func (cli *adminCLI) grantRed(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("grant-red", flag.ContinueOnError)
+	days := fs.Int("days", int(subscriptionPeriod/(24*time.Hour)), "length of the subscription")
+	arg, err := parseArgs(fs, args, "[-days 30] <user>")
+	if err != nil {
+		return err
+	}
+	if *days < 1 {
+		return errors.New("-days must be positive")
+	}
+	user, err := cli.lookupUser(ctx, arg)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	subscription, err := cli.cfg.store.SetSubscription(ctx, database.SetSubscriptionParams{
+		UserID:             user.ID,
+		Plan:               planChirpyRed,
+		Status:             subscriptionActive,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   now.Add(time.Duration(*days) * 24 * time.Hour),
+		Event:              adminEventGranted,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cli.out, "Granted Chirpy Red to %s until %s\n", user.Email, subscription.CurrentPeriodEnd.Format(time.RFC3339))
+	return nil
+}
+
+func (cli *adminCLI) revokeRed(ctx context.Context, args []string) error {
+	arg, err := parseArgs(flag.NewFlagSet("revoke-red", flag.ContinueOnError), args, "<user>")
+	if err != nil {
+		return err
+	}
+	user, err := cli.lookupUser(ctx, arg)
+	if err != nil {
+		return err
+	}
+
+	current, err := cli.cfg.store.GetSubscription(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s has no subscription", user.Email)
+		}
+		return err
+	}
+
+	_, err = cli.cfg.store.SetSubscription(ctx, database.SetSubscriptionParams{
+		UserID:             user.ID,
+		Plan:               current.Plan,
+		Status:             subscriptionRevoked,
+		CurrentPeriodStart: current.CurrentPeriodStart,
+		CurrentPeriodEnd:   time.Now().UTC(),
+		Event:              adminEventRevoked,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cli.out, "Revoked %s's subscription\n", user.Email)
+	return nil
+}
+
+func (cli *adminCLI) revokeSessions(ctx context.Context, args []string) error {
+	arg, err := parseArgs(flag.NewFlagSet("revoke-sessions", flag.ContinueOnError), args, "<user>")
+	if err != nil {
+		return err
+	}
+	user, err := cli.lookupUser(ctx, arg)
+	if err != nil {
+		return err
+	}
+
+	err = cli.cfg.store.RevokeAllRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Access tokens already issued stay valid until they expire
+	fmt.Fprintf(cli.out, "Revoked every session for %s\n", user.Email)
+	return nil
+}
+
+func (cli *adminCLI) deleteChirp(ctx context.Context, args []string) error {
+	arg, err := parseArgs(flag.NewFlagSet("delete-chirp", flag.ContinueOnError), args, "<chirpID>")
+	if err != nil {
+		return err
+	}
+	chirpID, err := uuid.Parse(arg)
+	if err != nil {
+		return fmt.Errorf("invalid chirp ID: %w", err)
+	}
+
+	chirp, err := cli.cfg.store.DetailChirp(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("couldn't find chirp %s", chirpID)
+		}
+		return err
+	}
+	err = cli.cfg.store.DeleteChirp(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	cli.cfg.enqueueWebhookEvent(ctx, chirp.UserID, webhookEventChirpDeleted, fromDbChirp(&chirp))
+
+	fmt.Fprintf(cli.out, "Deleted chirp %s\n", chirpID)
+	return nil
+}
+
+func (cli *adminCLI) stats(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: chirpy admin stats")
+	}
+	if cli.cfg.db == nil {
+		return errors.New("stats are only available with Postgres")
+	}
+
+	stats, err := cli.cfg.db.GetInstanceStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Users\t%d\n", stats.Users)
+	fmt.Fprintf(w, "Verified users\t%d\n", stats.VerifiedUsers)
+	fmt.Fprintf(w, "Users pending deletion\t%d\n", stats.UsersPendingDeletion)
+	fmt.Fprintf(w, "Subscribers\t%d\n", stats.Subscribers)
+	fmt.Fprintf(w, "Chirps\t%d\n", stats.Chirps)
+	fmt.Fprintf(w, "Chirps in the last day\t%d\n", stats.ChirpsLastDay)
+	fmt.Fprintf(w, "Active sessions\t%d\n", stats.ActiveSessions)
+	fmt.Fprintf(w, "Webhook endpoints\t%d\n", stats.WebhookEndpoints)
+	fmt.Fprintf(w, "Pending webhook deliveries\t%d\n", stats.PendingWebhookDeliveries)
+	fmt.Fprintf(w, "Dead webhook deliveries\t%d\n", stats.DeadWebhookDeliveries)
+	return w.Flush()
+}
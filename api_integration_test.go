@@ -1,11 +1,30 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
 )
 
 func TestUserChirpWorkflow(t *testing.T) {
@@ -359,3 +378,2126 @@ func TestChirpDeletion(t *testing.T) {
 		}
 	})
 }
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	var accessToken string
+	var apiKey string
+	var apiKeyID string
+
+	client := &http.Client{}
+
+	// Create user and login
+	t.Run("Create user and login", func(t *testing.T) {
+		body := map[string]any{"email": "bot@example.com", "password": "password123"}
+		jsonBody, _ := json.Marshal(body)
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+	})
+
+	// Create API key
+	t.Run("Create API key", func(t *testing.T) {
+		body := map[string]any{"name": "bot", "scopes": []string{"chirps:write"}}
+		jsonBody, _ := json.Marshal(body)
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/api_keys", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to create API key: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Fatalf("Status code = %d, expected 201", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var keyResp map[string]any
+		json.Unmarshal(respBody, &keyResp)
+		apiKey = keyResp["key"].(string)
+		apiKeyID = keyResp["id"].(string)
+	})
+
+	// Create chirp with the API key
+	t.Run("Create chirp with API key", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"body": "Beep boop"})
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/chirps", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Errorf("Status code = %d, expected 201", resp.StatusCode)
+		}
+	})
+
+	// API key without users:write can't update the user
+	t.Run("Update user without scope", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "bot@example.com", "password": "password123"})
+
+		req, _ := http.NewRequest("PUT", server.URL+"/api/users", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 403 {
+			t.Errorf("Status code = %d, expected 403", resp.StatusCode)
+		}
+	})
+
+	// Revoked keys are rejected
+	t.Run("Revoke API key", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", server.URL+"/api/api_keys/"+apiKeyID, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to revoke API key: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != 204 {
+			t.Fatalf("Status code = %d, expected 204", resp.StatusCode)
+		}
+
+		jsonBody, _ := json.Marshal(map[string]any{"body": "Still here?"})
+		req, _ = http.NewRequest("POST", server.URL+"/api/chirps", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	const redirectURI = "http://localhost:9999/callback"
+	verifier := strings.Repeat("v", 64)
+
+	var accessToken string
+	var clientID string
+	var code string
+	var refreshToken string
+
+	// Don't follow the redirect back to the client
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// Create user and login
+	t.Run("Create user and login", func(t *testing.T) {
+		body := map[string]any{"email": "jesse@example.com", "password": "password123"}
+		jsonBody, _ := json.Marshal(body)
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+	})
+
+	// Register a public client
+	t.Run("Register client", func(t *testing.T) {
+		body := map[string]any{"name": "Test App", "redirect_uris": []string{redirectURI}}
+		jsonBody, _ := json.Marshal(body)
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/oauth/clients", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to register client: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Fatalf("Status code = %d, expected 201", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var clientResp map[string]any
+		json.Unmarshal(respBody, &clientResp)
+		clientID = clientResp["client_id"].(string)
+	})
+
+	// Approve the consent page
+	t.Run("Authorize", func(t *testing.T) {
+		form := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectURI},
+			"state":                 {"xyz"},
+			"code_challenge":        {auth.MakeCodeChallenge(verifier)},
+			"code_challenge_method": {"S256"},
+			"email":                 {"jesse@example.com"},
+			"password":              {"password123"},
+			"action":                {"approve"},
+		}
+
+		resp, err := client.PostForm(server.URL+"/oauth/authorize", form)
+		if err != nil {
+			t.Fatalf("Failed to authorize: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 302 {
+			t.Fatalf("Status code = %d, expected 302", resp.StatusCode)
+		}
+
+		location, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			t.Fatalf("Failed to parse redirect: %v", err)
+		}
+		if location.Query().Get("state") != "xyz" {
+			t.Errorf("state = %q, expected xyz", location.Query().Get("state"))
+		}
+		code = location.Query().Get("code")
+	})
+
+	// Exchange the code for tokens
+	t.Run("Exchange code", func(t *testing.T) {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientID},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}
+
+		resp, err := client.PostForm(server.URL+"/oauth/token", form)
+		if err != nil {
+			t.Fatalf("Failed to exchange code: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var tokenResp map[string]any
+		json.Unmarshal(respBody, &tokenResp)
+		accessToken = tokenResp["access_token"].(string)
+		refreshToken = tokenResp["refresh_token"].(string)
+	})
+
+	// Codes can only be used once
+	t.Run("Reuse code", func(t *testing.T) {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientID},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}
+
+		resp, err := client.PostForm(server.URL+"/oauth/token", form)
+		if err != nil {
+			t.Fatalf("Failed to exchange code: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400", resp.StatusCode)
+		}
+	})
+
+	// The issued access token acts on behalf of the user
+	t.Run("Create chirp with OAuth token", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"body": "Yeah science!"})
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/chirps", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Errorf("Status code = %d, expected 201", resp.StatusCode)
+		}
+	})
+
+	// OAuth refresh tokens only work at /oauth/token, where they're rotated
+	t.Run("OAuth refresh token rejected by /api/refresh", func(t *testing.T) {
+		for _, path := range []string{"/api/refresh", "/api/revoke"} {
+			req, _ := http.NewRequest("POST", server.URL+path, nil)
+			req.Header.Set("Authorization", "Bearer "+refreshToken)
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != 401 {
+				t.Errorf("%s status code = %d, expected 401", path, resp.StatusCode)
+			}
+		}
+	})
+
+	// Reusing a rotated refresh token revokes the tokens rotated from it
+	t.Run("Rotate refresh token and detect reuse", func(t *testing.T) {
+		refresh := func(t *testing.T, token string) (int, map[string]any) {
+			t.Helper()
+			resp, err := client.PostForm(server.URL+"/oauth/token", url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {clientID},
+				"refresh_token": {token},
+			})
+			if err != nil {
+				t.Fatalf("Failed to refresh token: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var tokenResp map[string]any
+			json.NewDecoder(resp.Body).Decode(&tokenResp)
+			return resp.StatusCode, tokenResp
+		}
+
+		status, tokenResp := refresh(t, refreshToken)
+		if status != 200 {
+			t.Fatalf("Status code = %d, expected 200", status)
+		}
+		rotated := tokenResp["refresh_token"].(string)
+
+		if status, _ := refresh(t, refreshToken); status != 400 {
+			t.Errorf("Reused token status code = %d, expected 400", status)
+		}
+		if status, _ := refresh(t, rotated); status != 400 {
+			t.Errorf("Rotated token status code after reuse = %d, expected 400", status)
+		}
+	})
+
+	// Revoked refresh tokens can't be exchanged
+	t.Run("Revoke refresh token", func(t *testing.T) {
+		resp, err := client.PostForm(server.URL+"/oauth/revoke", url.Values{
+			"client_id": {clientID},
+			"token":     {refreshToken},
+		})
+		if err != nil {
+			t.Fatalf("Failed to revoke token: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		resp, err = client.PostForm(server.URL+"/oauth/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {clientID},
+			"refresh_token": {refreshToken},
+		})
+		if err != nil {
+			t.Fatalf("Failed to refresh token: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	credentials, _ := json.Marshal(map[string]any{"email": "skyler@example.com", "password": "password123"})
+
+	var accessToken string
+	var secret string
+	var recoveryCodes []any
+	var mfaToken string
+
+	client := &http.Client{}
+
+	// Create user and login
+	t.Run("Create user and login", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(credentials))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(credentials))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+	})
+
+	// Enroll and confirm
+	t.Run("Enroll", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", server.URL+"/api/users/mfa", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to enroll: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Fatalf("Status code = %d, expected 201", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var enrollResp map[string]any
+		json.Unmarshal(respBody, &enrollResp)
+		secret = enrollResp["secret"].(string)
+	})
+
+	t.Run("Confirm", func(t *testing.T) {
+		code, _ := totp.GenerateCode(secret, time.Now())
+		jsonBody, _ := json.Marshal(map[string]any{"code": code})
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/users/mfa/confirm", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to confirm: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var confirmResp map[string]any
+		json.Unmarshal(respBody, &confirmResp)
+		recoveryCodes = confirmResp["recovery_codes"].([]any)
+	})
+
+	// Password alone now only yields a challenge
+	t.Run("Login returns challenge", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(credentials))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		if _, ok := loginResp["token"]; ok {
+			t.Fatal("Login returned an access token without a second factor")
+		}
+		mfaToken = loginResp["mfa_token"].(string)
+	})
+
+	// The challenge token isn't an access token
+	t.Run("Challenge token is rejected", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"body": "Sneaky"})
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/chirps", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+mfaToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+
+	// A recovery code completes the login exactly once
+	t.Run("Login with recovery code", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"mfa_token": mfaToken, "recovery_code": recoveryCodes[0]})
+
+		resp, err := http.Post(server.URL+"/api/login/mfa", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		resp, err = http.Post(server.URL+"/api/login/mfa", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401 for reused recovery code", resp.StatusCode)
+		}
+	})
+
+	// Neither a TOTP code nor a challenge token completes a second login
+	t.Run("TOTP code and challenge are single use", func(t *testing.T) {
+		challenge := func(t *testing.T) string {
+			t.Helper()
+			resp, err := http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(credentials))
+			if err != nil {
+				t.Fatalf("Failed to login: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var loginResp map[string]any
+			json.NewDecoder(resp.Body).Decode(&loginResp)
+			return loginResp["mfa_token"].(string)
+		}
+		loginMFA := func(t *testing.T, body map[string]any) int {
+			t.Helper()
+			jsonBody, _ := json.Marshal(body)
+			resp, err := http.Post(server.URL+"/api/login/mfa", "application/json", bytes.NewBuffer(jsonBody))
+			if err != nil {
+				t.Fatalf("Failed to login: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		// The code used to confirm enrollment is for the current step
+		code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+		first := challenge(t)
+		if status := loginMFA(t, map[string]any{"mfa_token": first, "code": code}); status != 200 {
+			t.Fatalf("Status code = %d, expected 200", status)
+		}
+		if status := loginMFA(t, map[string]any{"mfa_token": challenge(t), "code": code}); status != 401 {
+			t.Errorf("Status code = %d, expected 401 for a replayed code", status)
+		}
+		if status := loginMFA(t, map[string]any{"mfa_token": first, "recovery_code": recoveryCodes[1]}); status != 401 {
+			t.Errorf("Status code = %d, expected 401 for a used challenge", status)
+		}
+		// The used challenge was rejected before the recovery code was checked
+		if status := loginMFA(t, map[string]any{"mfa_token": challenge(t), "recovery_code": recoveryCodes[1]}); status != 200 {
+			t.Errorf("Status code = %d, expected 200 for a recovery code sent with a used challenge", status)
+		}
+	})
+
+	// OAuth consent issues tokens too, so it can't skip the second factor
+	t.Run("OAuth consent requires a second factor", func(t *testing.T) {
+		const redirectURI = "http://localhost:9999/callback"
+		noRedirect := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		jsonBody, _ := json.Marshal(map[string]any{"name": "Test App", "redirect_uris": []string{redirectURI}})
+		req, _ := http.NewRequest("POST", server.URL+"/api/oauth/clients", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to register client: %v", err)
+		}
+		var clientResp map[string]any
+		json.NewDecoder(resp.Body).Decode(&clientResp)
+		resp.Body.Close()
+
+		form := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientResp["client_id"].(string)},
+			"redirect_uri":          {redirectURI},
+			"code_challenge":        {auth.MakeCodeChallenge(strings.Repeat("v", 64))},
+			"code_challenge_method": {"S256"},
+			"email":                 {"skyler@example.com"},
+			"password":              {"password123"},
+			"action":                {"approve"},
+		}
+
+		resp, err = noRedirect.PostForm(server.URL+"/oauth/authorize", form)
+		if err != nil {
+			t.Fatalf("Failed to authorize: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 401 {
+			t.Fatalf("Status code without a code = %d, expected 401", resp.StatusCode)
+		}
+
+		// TOTP codes for this window are used up, so use a recovery code
+		form.Set("code", recoveryCodes[2].(string))
+		resp, err = noRedirect.PostForm(server.URL+"/oauth/authorize", form)
+		if err != nil {
+			t.Fatalf("Failed to authorize: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 302 {
+			t.Errorf("Status code with a code = %d, expected 302", resp.StatusCode)
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	var refreshToken string
+	var resetToken string
+
+	// Create user and login
+	t.Run("Create user and login", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "saul@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		refreshToken = loginResp["refresh_token"].(string)
+	})
+
+	// Unknown emails look the same as known ones
+	t.Run("Request reset for unknown email", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "nobody@example.com"})
+
+		resp, err := http.Post(server.URL+"/api/password-reset/request", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to request reset: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 202 {
+			t.Errorf("Status code = %d, expected 202", resp.StatusCode)
+		}
+	})
+
+	t.Run("Request reset", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "saul@example.com"})
+
+		resp, err := http.Post(server.URL+"/api/password-reset/request", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to request reset: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 202 {
+			t.Fatalf("Status code = %d, expected 202", resp.StatusCode)
+		}
+
+		// The email is sent after responding
+		cfg.background.Wait()
+		messages := cfg.mailer.(*mailer.MemoryMailer).Messages()
+		if len(messages) == 0 {
+			t.Fatal("No reset email was sent")
+		}
+		message := messages[len(messages)-1]
+		if message.To != "saul@example.com" {
+			t.Errorf("Email sent to %s, expected saul@example.com", message.To)
+		}
+
+		match := regexp.MustCompile(`reset-password\?token=([0-9a-f]+)`).FindStringSubmatch(message.Body)
+		if match == nil {
+			t.Fatalf("Reset email has no token:\n%s", message.Body)
+		}
+		resetToken = match[1]
+	})
+
+	t.Run("Confirm reset", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"token": resetToken, "password": "better-call-saul"})
+
+		resp, err := http.Post(server.URL+"/api/password-reset/confirm", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to confirm reset: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != 204 {
+			t.Fatalf("Status code = %d, expected 204", resp.StatusCode)
+		}
+
+		// Tokens are single use
+		resp, err = http.Post(server.URL+"/api/password-reset/confirm", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to confirm reset: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400 for reused token", resp.StatusCode)
+		}
+	})
+
+	// Existing sessions are revoked
+	t.Run("Old refresh token is revoked", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", server.URL+"/api/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+refreshToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to refresh: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("Login with new password", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "saul@example.com", "password": "better-call-saul"})
+
+		resp, err := http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Errorf("Status code = %d, expected 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reset requests are rate limited per email", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "Saul@example.com"})
+
+		var status int
+		for range passwordResetEmailLimit {
+			resp, err := http.Post(server.URL+"/api/password-reset/request", "application/json", bytes.NewBuffer(jsonBody))
+			if err != nil {
+				t.Fatalf("Failed to request reset: %v", err)
+			}
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+		cfg.background.Wait()
+
+		if status != 429 {
+			t.Errorf("Status code = %d, expected 429 after %d requests", status, passwordResetEmailLimit)
+		}
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	var accessToken string
+	var refreshToken string
+
+	patch := func(t *testing.T, body map[string]any) *http.Response {
+		t.Helper()
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest("PATCH", server.URL+"/api/users/me", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to patch user: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Create user and login", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "mike@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+		refreshToken = loginResp["refresh_token"].(string)
+	})
+
+	t.Run("Change email without current password", func(t *testing.T) {
+		resp := patch(t, map[string]any{"email": "ehrmantraut@example.com"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("Change email only", func(t *testing.T) {
+		resp := patch(t, map[string]any{"email": "ehrmantraut@example.com", "current_password": "password123"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		checkJSONField(t, respBody, "email", "ehrmantraut@example.com")
+	})
+
+	// The password must survive an email-only change
+	t.Run("Login after email change", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "ehrmantraut@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Errorf("Status code = %d, expected 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("Change password", func(t *testing.T) {
+		resp := patch(t, map[string]any{"password": "no-half-measures", "current_password": "password123"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var patchResp map[string]any
+		json.Unmarshal(respBody, &patchResp)
+		if patchResp["refresh_token"] == nil {
+			t.Error("Expected a new session after a password change")
+		}
+	})
+
+	t.Run("Old session is revoked", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", server.URL+"/api/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+refreshToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to refresh: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+}
+
+func TestEmailVerification(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	t.Run("Create user normalizes email", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": " Gus@LosPollos.com ", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Fatalf("Status code = %d, expected 201", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		checkJSONField(t, respBody, "email", "gus@lospollos.com")
+		checkJSONField(t, respBody, "email_verified", false)
+	})
+
+	t.Run("Reject duplicate email in another case", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "GUS@lospollos.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 409 {
+			t.Errorf("Status code = %d, expected 409", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reject invalid email", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "not-an-email", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reject password that breaks the policy", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "hector@lospollos.com", "password": "ding"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Fatalf("Status code = %d, expected 400", resp.StatusCode)
+		}
+
+		var body struct {
+			Violations []auth.PasswordViolation `json:"violations"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if len(body.Violations) != 1 || body.Violations[0].Code != auth.PasswordTooShort {
+			t.Errorf("Violations = %+v, expected a single %q", body.Violations, auth.PasswordTooShort)
+		}
+	})
+
+	t.Run("Follow verification link", func(t *testing.T) {
+		messages := cfg.mailer.(*mailer.MemoryMailer).Messages()
+		if len(messages) != 1 {
+			t.Fatalf("Sent %d emails, expected 1", len(messages))
+		}
+
+		link := regexp.MustCompile(`http://\S+`).FindString(messages[0].Body)
+		link = strings.Replace(link, testBaseURL, server.URL, 1)
+
+		resp, err := http.Get(link)
+		if err != nil {
+			t.Fatalf("Failed to verify email: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		checkJSONField(t, respBody, "email_verified", true)
+	})
+
+	t.Run("Login is case-insensitive", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "GUS@LOSPOLLOS.COM", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Errorf("Status code = %d, expected 200", resp.StatusCode)
+		}
+	})
+
+	t.Run("Resends are rate limited per user", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "lydia@madrigal.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		token := loginResp["token"].(string)
+
+		for i := range emailVerificationResendLimit + 1 {
+			req, _ := http.NewRequest("POST", server.URL+"/api/users/verify-email/resend", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to resend verification: %v", err)
+			}
+			resp.Body.Close()
+
+			expected := 202
+			if i == emailVerificationResendLimit {
+				expected = 429
+				if resp.Header.Get("Retry-After") == "" {
+					t.Error("Missing Retry-After header")
+				}
+			}
+			if resp.StatusCode != expected {
+				t.Errorf("Status code = %d, expected %d for resend %d", resp.StatusCode, expected, i+1)
+			}
+		}
+	})
+}
+
+func TestAccountDeletionAndExport(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	var accessToken string
+	var userID string
+
+	deleteAccount := func(t *testing.T, password string) *http.Response {
+		t.Helper()
+		jsonBody, _ := json.Marshal(map[string]any{"password": password})
+		req, _ := http.NewRequest("DELETE", server.URL+"/api/users/me", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to delete account: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Create user, login and chirp", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "lydia@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+		userID = loginResp["id"].(string)
+
+		chirpBody, _ := json.Marshal(map[string]any{"body": "Stevia is the future"})
+		req, _ := http.NewRequest("POST", server.URL+"/api/chirps", bytes.NewBuffer(chirpBody))
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		chirpResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+		chirpResp.Body.Close()
+	})
+
+	t.Run("Export data", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", server.URL+"/api/users/me/export", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		archive, err := zip.NewReader(bytes.NewReader(respBody), int64(len(respBody)))
+		if err != nil {
+			t.Fatalf("Export isn't a zip archive: %v", err)
+		}
+
+		for _, f := range archive.File {
+			if f.Name != "chirps.json" {
+				continue
+			}
+			rc, _ := f.Open()
+			var chirps []map[string]any
+			json.NewDecoder(rc).Decode(&chirps)
+			rc.Close()
+			if len(chirps) != 1 || chirps[0]["body"] != "Stevia is the future" {
+				t.Errorf("chirps.json = %v, expected the posted chirp", chirps)
+			}
+			return
+		}
+		t.Error("Export is missing chirps.json")
+	})
+
+	t.Run("Delete with wrong password", func(t *testing.T) {
+		resp := deleteAccount(t, "wrong-password")
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("Delete and cancel", func(t *testing.T) {
+		resp := deleteAccount(t, "password123")
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 202 {
+			t.Fatalf("Status code = %d, expected 202", resp.StatusCode)
+		}
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/users/me/cancel-deletion", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		cancelResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to cancel deletion: %v", err)
+		}
+		defer cancelResp.Body.Close()
+
+		if cancelResp.StatusCode != 200 {
+			t.Errorf("Status code = %d, expected 200", cancelResp.StatusCode)
+		}
+	})
+
+	t.Run("Purge after grace period", func(t *testing.T) {
+		status := sendPolkaWebhook(t, server, "evt-lydia", "user.upgraded", userID)
+		if status != http.StatusNoContent {
+			t.Fatalf("Webhook returned %d, expected 204", status)
+		}
+
+		resp := deleteAccount(t, "password123")
+		resp.Body.Close()
+
+		// Pretend the grace period has passed
+		_, err := db.Exec("UPDATE users SET deletion_requested_at = now() - interval '31 days'")
+		if err != nil {
+			t.Fatalf("Failed to backdate deletion: %v", err)
+		}
+
+		err = cfg.purgeDeletedAccounts(context.Background())
+		if err != nil {
+			t.Fatalf("purgeDeletedAccounts() returned an error: %v", err)
+		}
+
+		var remaining int
+		db.QueryRow("SELECT count(*) FROM users WHERE id = $1", userID).Scan(&remaining)
+		if remaining != 0 {
+			t.Error("User still exists after purge")
+		}
+		db.QueryRow("SELECT count(*) FROM chirps WHERE user_id = $1", userID).Scan(&remaining)
+		if remaining != 0 {
+			t.Error("Chirps still exist after purge")
+		}
+		db.QueryRow("SELECT count(*) FROM webhook_events WHERE id = 'evt-lydia'").Scan(&remaining)
+		if remaining != 0 {
+			t.Error("Webhook events naming the user still exist after purge")
+		}
+	})
+}
+
+func TestPolkaWebhooks(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	var userID string
+
+	send := func(t *testing.T, body []byte, timestamp time.Time, secret string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", server.URL+"/api/polka/webhooks", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+		req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(secret, timestamp, body))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send webhook: %v", err)
+		}
+		return resp
+	}
+
+	isChirpyRed := func(t *testing.T, userID string) bool {
+		t.Helper()
+		_, err := queries.GetActivePlan(context.Background(), uuid.MustParse(userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return false
+		}
+		if err != nil {
+			t.Fatalf("Failed to check Red status: %v", err)
+		}
+		return true
+	}
+
+	t.Run("Create user", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "tuco@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var userResp map[string]any
+		json.Unmarshal(respBody, &userResp)
+		userID = userResp["id"].(string)
+	})
+
+	t.Run("Reject wrong key", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]any{"user_id": userID}})
+		resp := send(t, body, time.Now(), "not-the-polka-key")
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reject stale delivery", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]any{"user_id": userID}})
+		resp := send(t, body, time.Now().Add(-time.Hour), testPolkaKey)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("Upgrade user", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]any{"user_id": userID}})
+		resp := send(t, body, time.Now(), testPolkaKey)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 204 {
+			t.Fatalf("Status code = %d, expected 204", resp.StatusCode)
+		}
+
+		if !isChirpyRed(t, userID) {
+			t.Error("User wasn't upgraded")
+		}
+	})
+
+	t.Run("Refund removes Red", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"id": "evt_2", "event": "subscription.refunded", "data": map[string]any{"user_id": userID}})
+		resp := send(t, body, time.Now(), testPolkaKey)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 204 {
+			t.Fatalf("Status code = %d, expected 204", resp.StatusCode)
+		}
+		if isChirpyRed(t, userID) {
+			t.Error("User is still Red after a refund")
+		}
+	})
+
+	// A replay is acknowledged but not processed again
+	t.Run("Ignore replayed event", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]any{"user_id": userID}})
+		resp := send(t, body, time.Now(), testPolkaKey)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 204 {
+			t.Fatalf("Status code = %d, expected 204", resp.StatusCode)
+		}
+		if isChirpyRed(t, userID) {
+			t.Error("Replayed event was processed again")
+		}
+	})
+
+	t.Run("Failed event is logged and can be replayed", func(t *testing.T) {
+		unknownUser := uuid.New().String()
+		body, _ := json.Marshal(map[string]any{"id": "evt_3", "event": "user.upgraded", "data": map[string]any{"user_id": unknownUser}})
+		resp := send(t, body, time.Now(), testPolkaKey)
+		resp.Body.Close()
+
+		if resp.StatusCode != 404 {
+			t.Fatalf("Status code = %d, expected 404", resp.StatusCode)
+		}
+
+		req, _ := http.NewRequest("GET", server.URL+"/admin/webhooks?status=failed", nil)
+		req.Header.Set("Authorization", "ApiKey "+testAdminKey)
+		listResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to list webhook events: %v", err)
+		}
+		defer listResp.Body.Close()
+
+		var events []map[string]any
+		json.NewDecoder(listResp.Body).Decode(&events)
+		if len(events) != 1 || events[0]["id"] != "evt_3" || events[0]["response_code"] != float64(404) {
+			t.Fatalf("Failed events = %v, expected evt_3 with response code 404", events)
+		}
+
+		req, _ = http.NewRequest("POST", server.URL+"/admin/webhooks/evt_3/replay", nil)
+		req.Header.Set("Authorization", "ApiKey "+testAdminKey)
+		replayResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to replay webhook event: %v", err)
+		}
+		defer replayResp.Body.Close()
+
+		if replayResp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", replayResp.StatusCode)
+		}
+		respBody, _ := io.ReadAll(replayResp.Body)
+		checkJSONField(t, respBody, "attempts", float64(2))
+	})
+
+	t.Run("Admin endpoints need the admin key", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", server.URL+"/admin/webhooks", nil)
+		req.Header.Set("Authorization", "ApiKey not-the-admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to list webhook events: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 401 {
+			t.Errorf("Status code = %d, expected 401", resp.StatusCode)
+		}
+	})
+}
+
+func TestEntitlements(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	var accessToken string
+	var userID string
+	var chirpID string
+	longBody := strings.Repeat("a", 200)
+
+	do := func(t *testing.T, method, path string, body map[string]any) *http.Response {
+		t.Helper()
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("Create user and login", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "hector@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+		userID = loginResp["id"].(string)
+	})
+
+	t.Run("Free plan limits", func(t *testing.T) {
+		resp := do(t, "GET", "/api/users/me/entitlements", nil)
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		checkJSONField(t, respBody, "plan", "free")
+		checkJSONField(t, respBody, "max_chirp_length", 140)
+		checkJSONField(t, respBody, "can_edit_chirps", false)
+	})
+
+	t.Run("Free user can't post a long chirp", func(t *testing.T) {
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": longBody})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("Free user can't edit a chirp", func(t *testing.T) {
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": "Tio"})
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var chirpResp map[string]any
+		json.Unmarshal(respBody, &chirpResp)
+		chirpID = chirpResp["id"].(string)
+
+		resp = do(t, "PUT", "/api/chirps/"+chirpID, map[string]any{"body": "Ding"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 403 {
+			t.Errorf("Status code = %d, expected 403", resp.StatusCode)
+		}
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+		now := time.Now()
+		_, err := queries.SetSubscription(context.Background(), database.SetSubscriptionParams{
+			UserID:             uuid.MustParse(userID),
+			Plan:               planChirpyRed,
+			Status:             subscriptionActive,
+			CurrentPeriodStart: now,
+			CurrentPeriodEnd:   now.Add(subscriptionPeriod),
+			Event:              adminEventGranted,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+	})
+
+	t.Run("Red user can post a long chirp", func(t *testing.T) {
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": longBody})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Errorf("Status code = %d, expected 201", resp.StatusCode)
+		}
+	})
+
+	t.Run("Red user can edit a chirp", func(t *testing.T) {
+		resp := do(t, "PUT", "/api/chirps/"+chirpID, map[string]any{"body": "Ding ding"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		checkJSONField(t, respBody, "body", "Ding ding")
+	})
+
+	t.Run("Red user has a badge", func(t *testing.T) {
+		resp := do(t, "PATCH", "/api/users/me", map[string]any{})
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		checkJSONField(t, respBody, "is_chirpy_red", true)
+		checkJSONField(t, respBody, "badge", "red")
+	})
+
+	t.Run("Chirps are rate limited", func(t *testing.T) {
+		cfg.entitlements = map[string]Entitlements{
+			planFree:      defaultEntitlements[planFree],
+			planChirpyRed: {MaxChirpLength: 1000, ChirpsPerHour: 2},
+		}
+		defer func() { cfg.entitlements = defaultEntitlements }()
+
+		// Two chirps have been posted already this hour
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": "Ding"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 429 {
+			t.Errorf("Status code = %d, expected 429", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") == "" {
+			t.Error("Missing Retry-After header")
+		}
+	})
+}
+
+func TestOutboundWebhooks(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	// Local receiver; its status code is switched to simulate failures
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var mu sync.Mutex
+	var deliveries []received
+	receiverStatus := http.StatusOK
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, received{header: r.Header, body: body})
+		w.WriteHeader(receiverStatus)
+	}))
+	defer receiver.Close()
+	cfg.webhookClient = receiver.Client()
+
+	var accessToken string
+	var endpointID string
+	var secret string
+
+	do := func(t *testing.T, method, path string, body any) *http.Response {
+		t.Helper()
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	dispatch := func(t *testing.T) {
+		t.Helper()
+		if err := cfg.dispatchWebhooks(context.Background()); err != nil {
+			t.Fatalf("dispatchWebhooks() returned an error: %v", err)
+		}
+	}
+
+	receivedDeliveries := func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(deliveries)
+	}
+
+	setReceiverStatus := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		receiverStatus = code
+		deliveries = nil
+	}
+
+	// Makes every pending delivery due now instead of after its backoff
+	makeDue := func(t *testing.T) {
+		t.Helper()
+		if _, err := db.Exec("UPDATE webhook_deliveries SET next_attempt_at = now() WHERE status = 'pending'"); err != nil {
+			t.Fatalf("Failed to update deliveries: %v", err)
+		}
+	}
+
+	listDeliveries := func(t *testing.T) []map[string]any {
+		t.Helper()
+		resp := do(t, "GET", "/api/webhooks/"+endpointID+"/deliveries", nil)
+		defer resp.Body.Close()
+
+		var log []map[string]any
+		json.NewDecoder(resp.Body).Decode(&log)
+		return log
+	}
+
+	t.Run("Create user and login", func(t *testing.T) {
+		jsonBody, _ := json.Marshal(map[string]any{"email": "gus@example.com", "password": "password123"})
+
+		resp, err := http.Post(server.URL+"/api/users", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		resp.Body.Close()
+
+		resp, err = http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatalf("Failed to login: %v", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var loginResp map[string]any
+		json.Unmarshal(respBody, &loginResp)
+		accessToken = loginResp["token"].(string)
+	})
+
+	t.Run("Reject plain http URL", func(t *testing.T) {
+		resp := do(t, "POST", "/api/webhooks", map[string]any{"url": "http://example.com/hook", "events": []string{"chirp.created"}})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("Reject unknown event", func(t *testing.T) {
+		resp := do(t, "POST", "/api/webhooks", map[string]any{"url": receiver.URL, "events": []string{"chirp.liked"}})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 400 {
+			t.Errorf("Status code = %d, expected 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("Register endpoint", func(t *testing.T) {
+		resp := do(t, "POST", "/api/webhooks", map[string]any{"url": receiver.URL, "events": []string{"chirp.created", "chirp.deleted"}})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 201 {
+			t.Fatalf("Status code = %d, expected 201", resp.StatusCode)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		var endpointResp map[string]any
+		json.Unmarshal(respBody, &endpointResp)
+		endpointID = endpointResp["id"].(string)
+		secret = endpointResp["secret"].(string)
+		if !strings.HasPrefix(secret, "whsec_") {
+			t.Errorf("secret = %q, expected a whsec_ prefix", secret)
+		}
+	})
+
+	t.Run("Deliver signed event", func(t *testing.T) {
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": "Los Pollos Hermanos"})
+		resp.Body.Close()
+
+		dispatch(t)
+
+		got := receivedDeliveries()
+		if len(got) != 1 {
+			t.Fatalf("Receiver got %d deliveries, expected 1", len(got))
+		}
+		d := got[0]
+		if got := d.header.Get(webhookEventHeader); got != "chirp.created" {
+			t.Errorf("%s = %q, expected chirp.created", webhookEventHeader, got)
+		}
+		ts, err := strconv.ParseInt(d.header.Get(webhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid timestamp header: %v", err)
+		}
+		expected := auth.SignWebhook(secret, time.Unix(ts, 0), d.body)
+		if got := d.header.Get(webhookSignatureHeader); got != expected {
+			t.Errorf("%s = %q, expected %q", webhookSignatureHeader, got, expected)
+		}
+		checkJSONField(t, d.body, "data.body", "Los Pollos Hermanos")
+
+		log := listDeliveries(t)
+		if len(log) != 1 || log[0]["status"] != "delivered" {
+			t.Errorf("Delivery log = %v, expected one delivered entry", log)
+		}
+	})
+
+	t.Run("Retry with backoff then dead-letter", func(t *testing.T) {
+		setReceiverStatus(http.StatusInternalServerError)
+
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": "Not delivered"})
+		resp.Body.Close()
+
+		dispatch(t)
+		if got := len(receivedDeliveries()); got != 1 {
+			t.Fatalf("Receiver got %d deliveries, expected 1", got)
+		}
+
+		// Not due again until the backoff has passed
+		dispatch(t)
+		if got := len(receivedDeliveries()); got != 1 {
+			t.Fatalf("Receiver got %d deliveries, expected no retry before the backoff", got)
+		}
+
+		log := listDeliveries(t)
+		checkJSONField(t, mustJSON(log[0]), "status", "pending")
+		checkJSONField(t, mustJSON(log[0]), "response_code", 500)
+
+		// Skip ahead to the last attempt
+		if _, err := db.Exec("UPDATE webhook_deliveries SET attempts = $1 WHERE status = 'pending'", webhookMaxAttempts-1); err != nil {
+			t.Fatalf("Failed to update deliveries: %v", err)
+		}
+		makeDue(t)
+		dispatch(t)
+
+		log = listDeliveries(t)
+		checkJSONField(t, mustJSON(log[0]), "status", "dead")
+	})
+
+	t.Run("Retry dead-lettered delivery", func(t *testing.T) {
+		setReceiverStatus(http.StatusOK)
+
+		log := listDeliveries(t)
+		deliveryID := log[0]["id"].(string)
+
+		resp := do(t, "POST", "/api/webhooks/"+endpointID+"/deliveries/"+deliveryID+"/retry", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != 202 {
+			t.Fatalf("Status code = %d, expected 202", resp.StatusCode)
+		}
+
+		dispatch(t)
+		if got := len(receivedDeliveries()); got != 1 {
+			t.Fatalf("Receiver got %d deliveries, expected 1", got)
+		}
+		log = listDeliveries(t)
+		checkJSONField(t, mustJSON(log[0]), "status", "delivered")
+	})
+
+	t.Run("Unsubscribed events are not queued", func(t *testing.T) {
+		cfg.entitlements = map[string]Entitlements{
+			planFree: {MaxChirpLength: 140, CanEditChirps: true, ChirpsPerHour: 30},
+		}
+		defer func() { cfg.entitlements = defaultEntitlements }()
+
+		log := listDeliveries(t)
+		chirpID := log[0]["payload"].(map[string]any)["data"].(map[string]any)["id"].(string)
+
+		resp := do(t, "PUT", "/api/chirps/"+chirpID, map[string]any{"body": "Edited"})
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("Status code = %d, expected 200", resp.StatusCode)
+		}
+
+		if got := len(listDeliveries(t)); got != len(log) {
+			t.Errorf("Delivery log has %d entries, expected %d", got, len(log))
+		}
+	})
+
+	t.Run("Deliver mention to the mentioned user", func(t *testing.T) {
+		setReceiverStatus(http.StatusOK)
+
+		hector := memoryLogin(t, server, "hector@example.com")["token"].(string)
+		status, _ := memoryRequest(t, server, "POST", "/api/webhooks", hector, map[string]any{"url": receiver.URL, "events": []string{"chirp.mentioned"}})
+		if status != http.StatusCreated {
+			t.Fatalf("Registering the endpoint returned %d, expected 201", status)
+		}
+
+		resp := do(t, "POST", "/api/chirps", map[string]any{"body": "Ding ding @hector@example.com"})
+		resp.Body.Close()
+		dispatch(t)
+
+		var mentions int
+		for _, d := range receivedDeliveries() {
+			if d.header.Get(webhookEventHeader) == "chirp.mentioned" {
+				mentions++
+				checkJSONField(t, d.body, "data.body", "Ding ding @hector@example.com")
+			}
+		}
+		if mentions != 1 {
+			t.Errorf("Receiver got %d chirp.mentioned deliveries, expected 1", mentions)
+		}
+	})
+
+	t.Run("Delete endpoint", func(t *testing.T) {
+		resp := do(t, "DELETE", "/api/webhooks/"+endpointID, nil)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 204 {
+			t.Fatalf("Status code = %d, expected 204", resp.StatusCode)
+		}
+
+		resp = do(t, "GET", "/api/webhooks/"+endpointID+"/deliveries", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != 404 {
+			t.Errorf("Status code = %d, expected 404", resp.StatusCode)
+		}
+	})
+}
+
+func mustJSON(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestReadiness(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Create test config and server
+	cfg := createTestConfig(t, queries)
+	expected, err := expectedSchemaVersion(schemaFS, migrationsDir[backendPostgres])
+	if err != nil {
+		t.Fatalf("Failed to read embedded migrations: %v", err)
+	}
+	cfg.readinessChecks = databaseChecks(db, expected)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	t.Run("Liveness", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/livez")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Deprecated healthz still answers", func(t *testing.T) {
+		cfg.readinessChecks = databaseChecks(db, expected+1)
+		defer func() { cfg.readinessChecks = databaseChecks(db, expected) }()
+
+		resp, err := http.Get(server.URL + "/api/healthz")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200 even when not ready, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Ready when migrated", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/readyz")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Errorf("Expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("Not ready on an older schema", func(t *testing.T) {
+		cfg.readinessChecks = databaseChecks(db, expected+1)
+		defer func() { cfg.readinessChecks = databaseChecks(db, expected) }()
+
+		resp, err := http.Get(server.URL + "/api/readyz")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", resp.StatusCode)
+		}
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		if status, _ := getJSONField(result, "checks.migrations.status"); status != "failing" {
+			t.Errorf("Expected migrations check to be failing, got %v", status)
+		}
+	})
+
+	t.Run("Ready on a newer schema", func(t *testing.T) {
+		cfg.readinessChecks = databaseChecks(db, expected-1)
+		defer func() { cfg.readinessChecks = databaseChecks(db, expected) }()
+
+		resp, err := http.Get(server.URL + "/api/readyz")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		}
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		if note, _ := getJSONField(result, "checks.migrations.note"); note == nil {
+			t.Error("Expected a note on the migrations check")
+		}
+	})
+}
+
+func TestAdminCLI(t *testing.T) {
+	// Setup test database
+	db, queries := setupTestDB(t)
+	defer db.Close()
+
+	// Clean database before test
+	cleanupTestDB(t, db)
+
+	cfg := createTestConfig(t, queries)
+	server := setupTestServer(t, cfg)
+	defer server.Close()
+
+	run := func(t *testing.T, stdin string, args ...string) (string, error) {
+		t.Helper()
+		var out bytes.Buffer
+		cli := &adminCLI{
+			cfg: cfg,
+			in:  bufio.NewReader(strings.NewReader(stdin)),
+			out: &out,
+		}
+		err := cli.run(context.Background(), args)
+		return out.String(), err
+	}
+
+	login := func(t *testing.T, password string) *http.Response {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"email": "walt@example.com", "password": password})
+		resp, err := http.Post(server.URL+"/api/login", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	isChirpyRed := func(t *testing.T, userID uuid.UUID) bool {
+		t.Helper()
+		_, err := queries.GetActivePlan(context.Background(), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false
+		}
+		if err != nil {
+			t.Fatalf("Failed to check Red status: %v", err)
+		}
+		return true
+	}
+
+	var user database.User
+
+	t.Run("Create user", func(t *testing.T) {
+		if _, err := run(t, "short\n", "create-user", "walt@example.com"); err == nil {
+			t.Error("Expected a password violating the policy to be rejected")
+		}
+
+		out, err := run(t, "heisenberg99\n", "create-user", "-verified", "Walt@Example.com")
+		if err != nil {
+			t.Fatalf("create-user failed: %v", err)
+		}
+		if !strings.Contains(out, "walt@example.com") {
+			t.Errorf("Expected the normalized email in the output, got %q", out)
+		}
+
+		user, err = queries.GetUserByEmail(context.Background(), "walt@example.com")
+		if err != nil {
+			t.Fatalf("Failed to fetch user: %v", err)
+		}
+		if !user.EmailVerifiedAt.Valid {
+			t.Error("Expected the email to be verified")
+		}
+
+		resp := login(t, "heisenberg99")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected login to succeed, got %d", resp.StatusCode)
+		}
+
+		if _, err := run(t, "heisenberg99\n", "create-user", "walt@example.com"); err == nil {
+			t.Error("Expected a duplicate email to be rejected")
+		}
+	})
+
+	t.Run("Grant and revoke Red", func(t *testing.T) {
+		if _, err := run(t, "", "grant-red", "-days", "7", user.ID.String()); err != nil {
+			t.Fatalf("grant-red failed: %v", err)
+		}
+		if !isChirpyRed(t, user.ID) {
+			t.Error("Expected user to be Red")
+		}
+
+		if _, err := run(t, "", "revoke-red", "walt@example.com"); err != nil {
+			t.Fatalf("revoke-red failed: %v", err)
+		}
+		if isChirpyRed(t, user.ID) {
+			t.Error("Expected Red to end immediately")
+		}
+
+		events, err := queries.ListSubscriptionEventsForUser(context.Background(), user.ID)
+		if err != nil {
+			t.Fatalf("Failed to list subscription events: %v", err)
+		}
+		if len(events) != 2 || events[0].Event != adminEventGranted || events[1].Event != adminEventRevoked {
+			t.Errorf("Expected the grant and revoke in the history, got %+v", events)
+		}
+	})
+
+	t.Run("Revoke sessions and reset password", func(t *testing.T) {
+		resp := login(t, "heisenberg99")
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		refreshToken, _ := result["refresh_token"].(string)
+
+		if _, err := run(t, "", "revoke-sessions", "walt@example.com"); err != nil {
+			t.Fatalf("revoke-sessions failed: %v", err)
+		}
+
+		req, _ := http.NewRequest("POST", server.URL+"/api/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+refreshToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected the revoked refresh token to be rejected, got %d", resp.StatusCode)
+		}
+
+		if _, err := run(t, "sayMyName2024\n", "reset-password", "walt@example.com"); err != nil {
+			t.Fatalf("reset-password failed: %v", err)
+		}
+		resp = login(t, "sayMyName2024")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected login with the new password to succeed, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Delete chirp and stats", func(t *testing.T) {
+		chirp, err := queries.CreateChirp(context.Background(), database.CreateChirpParams{
+			Body:   "I am the one who knocks",
+			UserID: user.ID,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+
+		if _, err := run(t, "", "delete-chirp", chirp.ID.String()); err != nil {
+			t.Fatalf("delete-chirp failed: %v", err)
+		}
+		if _, err := queries.DetailChirp(context.Background(), chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected the chirp to be gone, got %v", err)
+		}
+		if _, err := run(t, "", "delete-chirp", chirp.ID.String()); err == nil {
+			t.Error("Expected deleting a missing chirp to fail")
+		}
+
+		out, err := run(t, "", "stats")
+		if err != nil {
+			t.Fatalf("stats failed: %v", err)
+		}
+		if !regexp.MustCompile(`(?m)^Users\s+1$`).MatchString(out) {
+			t.Errorf("Expected one user in the stats, got:\n%s", out)
+		}
+	})
+}
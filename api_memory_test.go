@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// memoryRequest sends a JSON request to a memory-backed test server and
+// decodes the JSON response, if there is one.
+func memoryRequest(t *testing.T, server *httptest.Server, method, path, token string, body any) (int, map[string]any) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reqBody).Encode(body)
+	}
+	req, _ := http.NewRequest(method, server.URL+path, &reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var respBody map[string]any
+	json.NewDecoder(resp.Body).Decode(&respBody)
+	return resp.StatusCode, respBody
+}
+
+// memoryLogin creates a user and logs them in, returning the login response
+func memoryLogin(t *testing.T, server *httptest.Server, email string) map[string]any {
+	t.Helper()
+
+	credentials := map[string]any{"email": email, "password": "heisenberg"}
+	status, _ := memoryRequest(t, server, "POST", "/api/users", "", credentials)
+	if status != http.StatusCreated {
+		t.Fatalf("Creating %s returned %d, expected 201", email, status)
+	}
+	status, login := memoryRequest(t, server, "POST", "/api/login", "", credentials)
+	if status != http.StatusOK {
+		t.Fatalf("Logging in as %s returned %d, expected 200", email, status)
+	}
+	return login
+}
+
+func TestMemoryUsers(t *testing.T) {
+	server, _ := setupMemoryTestServer(t)
+	login := memoryLogin(t, server, "walt@breakingbad.com")
+	token := login["token"].(string)
+
+	status, _ := memoryRequest(t, server, "POST", "/api/users", "", map[string]any{
+		"email":    "Walt@BreakingBad.com",
+		"password": "heisenberg",
+	})
+	if status != http.StatusConflict {
+		t.Errorf("Duplicate email returned %d, expected 409", status)
+	}
+
+	status, _ = memoryRequest(t, server, "POST", "/api/login", "", map[string]any{
+		"email":    "walt@breakingbad.com",
+		"password": "wrong password",
+	})
+	if status != http.StatusUnauthorized {
+		t.Errorf("Wrong password returned %d, expected 401", status)
+	}
+
+	status, _ = memoryRequest(t, server, "PUT", "/api/users", token, map[string]any{
+		"email":            "heisenberg@breakingbad.com",
+		"password":         "saymyname",
+		"current_password": "wrong password",
+	})
+	if status != http.StatusUnauthorized {
+		t.Errorf("Updating the user with the wrong current password returned %d, expected 401", status)
+	}
+
+	status, user := memoryRequest(t, server, "PUT", "/api/users", token, map[string]any{
+		"email":            "heisenberg@breakingbad.com",
+		"password":         "saymyname",
+		"current_password": "heisenberg",
+	})
+	if status != http.StatusOK || user["email"] != "heisenberg@breakingbad.com" {
+		t.Fatalf("Updating the user returned %d %v", status, user)
+	}
+	if user["refresh_token"] == nil {
+		t.Errorf("Changing the password didn't return a new session: %v", user)
+	}
+	status, _ = memoryRequest(t, server, "POST", "/api/login", "", map[string]any{
+		"email":    "heisenberg@breakingbad.com",
+		"password": "saymyname",
+	})
+	if status != http.StatusOK {
+		t.Errorf("Logging in with the new credentials returned %d, expected 200", status)
+	}
+}
+
+func TestMemoryChirps(t *testing.T) {
+	server, _ := setupMemoryTestServer(t)
+	walt := memoryLogin(t, server, "walt@breakingbad.com")["token"].(string)
+	jesse := memoryLogin(t, server, "jesse@breakingbad.com")["token"].(string)
+
+	var ids []string
+	for _, body := range []string{"I'm the one who knocks!", "Gale!"} {
+		status, chirp := memoryRequest(t, server, "POST", "/api/chirps", walt, map[string]any{"body": body})
+		if status != http.StatusCreated {
+			t.Fatalf("Creating a chirp returned %d, expected 201", status)
+		}
+		ids = append(ids, chirp["id"].(string))
+	}
+
+	resp, err := http.Get(server.URL + "/api/chirps")
+	if err != nil {
+		t.Fatalf("Failed to list chirps: %v", err)
+	}
+	var chirps []map[string]any
+	json.NewDecoder(resp.Body).Decode(&chirps)
+	resp.Body.Close()
+	if len(chirps) != 2 || chirps[0]["id"] != ids[0] {
+		t.Errorf("Listed chirps %v, expected both, oldest first", chirps)
+	}
+
+	status, _ := memoryRequest(t, server, "DELETE", "/api/chirps/"+ids[0], jesse, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("Deleting someone else's chirp returned %d, expected 403", status)
+	}
+	status, _ = memoryRequest(t, server, "DELETE", "/api/chirps/"+ids[0], walt, nil)
+	if status != http.StatusNoContent {
+		t.Errorf("Deleting a chirp returned %d, expected 204", status)
+	}
+	status, _ = memoryRequest(t, server, "GET", "/api/chirps/"+ids[0], "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("Getting a deleted chirp returned %d, expected 404", status)
+	}
+}
+
+func TestMemoryRefreshTokens(t *testing.T) {
+	server, _ := setupMemoryTestServer(t)
+	refreshToken := memoryLogin(t, server, "walt@breakingbad.com")["refresh_token"].(string)
+
+	status, refreshed := memoryRequest(t, server, "POST", "/api/refresh", refreshToken, nil)
+	if status != http.StatusOK || refreshed["token"] == "" {
+		t.Fatalf("Refreshing returned %d %v", status, refreshed)
+	}
+
+	status, _ = memoryRequest(t, server, "POST", "/api/revoke", refreshToken, nil)
+	if status != http.StatusNoContent {
+		t.Errorf("Revoking returned %d, expected 204", status)
+	}
+	status, _ = memoryRequest(t, server, "POST", "/api/refresh", refreshToken, nil)
+	if status != http.StatusUnauthorized {
+		t.Errorf("Refreshing with a revoked token returned %d, expected 401", status)
+	}
+}
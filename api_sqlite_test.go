@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"chirpy/internal/auth"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sendPolkaWebhook posts a signed Polka event and returns the status code
+func sendPolkaWebhook(t *testing.T, server *httptest.Server, id, event, userID string) int {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]any{"id": id, "event": event, "data": map[string]any{"user_id": userID}})
+	now := time.Now()
+	req, _ := http.NewRequest("POST", server.URL+"/api/polka/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(testPolkaKey, now, body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send webhook: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSQLiteUsersAndChirps(t *testing.T) {
+	server, _ := setupSQLiteTestServer(t)
+	login := memoryLogin(t, server, "walt@breakingbad.com")
+	walt := login["token"].(string)
+	jesse := memoryLogin(t, server, "jesse@breakingbad.com")["token"].(string)
+
+	status, _ := memoryRequest(t, server, "POST", "/api/users", "", map[string]any{
+		"email":    "Walt@BreakingBad.com",
+		"password": "heisenberg",
+	})
+	if status != http.StatusConflict {
+		t.Errorf("Duplicate email returned %d, expected 409", status)
+	}
+
+	status, chirp := memoryRequest(t, server, "POST", "/api/chirps", walt, map[string]any{"body": "I'm the one who knocks!"})
+	if status != http.StatusCreated {
+		t.Fatalf("Creating a chirp returned %d, expected 201", status)
+	}
+	chirpID := chirp["id"].(string)
+
+	status, got := memoryRequest(t, server, "GET", "/api/chirps/"+chirpID, "", nil)
+	if status != http.StatusOK || got["user_id"] != login["id"] {
+		t.Errorf("Getting the chirp returned %d %v", status, got)
+	}
+	status, _ = memoryRequest(t, server, "DELETE", "/api/chirps/"+chirpID, jesse, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("Deleting someone else's chirp returned %d, expected 403", status)
+	}
+	status, _ = memoryRequest(t, server, "DELETE", "/api/chirps/"+chirpID, walt, nil)
+	if status != http.StatusNoContent {
+		t.Errorf("Deleting a chirp returned %d, expected 204", status)
+	}
+
+	refreshToken := login["refresh_token"].(string)
+	status, _ = memoryRequest(t, server, "POST", "/api/refresh", refreshToken, nil)
+	if status != http.StatusOK {
+		t.Errorf("Refreshing returned %d, expected 200", status)
+	}
+	memoryRequest(t, server, "POST", "/api/revoke", refreshToken, nil)
+	status, _ = memoryRequest(t, server, "POST", "/api/refresh", refreshToken, nil)
+	if status != http.StatusUnauthorized {
+		t.Errorf("Refreshing with a revoked token returned %d, expected 401", status)
+	}
+}
+
+func TestSQLiteChirpyRed(t *testing.T) {
+	server, _ := setupSQLiteTestServer(t)
+	login := memoryLogin(t, server, "tuco@example.com")
+	token := login["token"].(string)
+	userID := login["id"].(string)
+
+	plan := func(t *testing.T) string {
+		t.Helper()
+		status, resp := memoryRequest(t, server, "GET", "/api/users/me/entitlements", token, nil)
+		if status != http.StatusOK {
+			t.Fatalf("Getting entitlements returned %d, expected 200", status)
+		}
+		return resp["plan"].(string)
+	}
+
+	if status := sendPolkaWebhook(t, server, "evt_1", "user.upgraded", userID); status != http.StatusNoContent {
+		t.Fatalf("Upgrade returned %d, expected 204", status)
+	}
+	if got := plan(t); got != planChirpyRed {
+		t.Errorf("Plan after upgrading = %q, expected %s", got, planChirpyRed)
+	}
+
+	if status := sendPolkaWebhook(t, server, "evt_2", "subscription.refunded", userID); status != http.StatusNoContent {
+		t.Fatalf("Refund returned %d, expected 204", status)
+	}
+	if got := plan(t); got != planFree {
+		t.Errorf("Plan after a refund = %q, expected %s", got, planFree)
+	}
+
+	// A replay is acknowledged but not processed again
+	if status := sendPolkaWebhook(t, server, "evt_1", "user.upgraded", userID); status != http.StatusNoContent {
+		t.Fatalf("Replay returned %d, expected 204", status)
+	}
+	if got := plan(t); got != planFree {
+		t.Errorf("Plan after a replay = %q, expected %s", got, planFree)
+	}
+}
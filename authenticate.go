@@ -0,0 +1,122 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	scopeChirpsWrite   = "chirps:write"
+	scopeUsersWrite    = "users:write"
+	scopeWebhooksWrite = "webhooks:write"
+)
+
+var validScopes = []string{scopeChirpsWrite, scopeUsersWrite, scopeWebhooksWrite}
+
+var errMissingScope = errors.New("API key is missing the required scope")
+
+// requireUser authenticates the request with either a bearer JWT or a
+// personal API key carrying scope. It writes the error response itself and
+// reports whether the handler should continue.
+func (cfg *apiConfig) requireUser(w http.ResponseWriter, r *http.Request, scope string) (uuid.UUID, bool) {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "ApiKey ") {
+		key, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			respondWithError(w, r, http.StatusUnauthorized, "Couldn't find API key", err)
+			return uuid.Nil, false
+		}
+
+		userID, err := cfg.validateAPIKey(r, key, scope)
+		if errors.Is(err, errMissingScope) {
+			respondWithError(w, r, http.StatusForbidden, err.Error(), err)
+			return uuid.Nil, false
+		}
+		if err != nil {
+			respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate API key", err)
+			return uuid.Nil, false
+		}
+		return userID, true
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func (cfg *apiConfig) validateAPIKey(r *http.Request, key, scope string) (uuid.UUID, error) {
+	if cfg.db == nil {
+		return uuid.Nil, errors.New("API keys are only available with Postgres")
+	}
+
+	prefix, err := auth.ParseAPIKey(key)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	dbKey, err := cfg.db.GetAPIKeyByPrefix(r.Context(), prefix)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	match, err := auth.CheckPasswordHash(key, dbKey.HashedKey)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !match {
+		return uuid.Nil, errors.New("API key doesn't match")
+	}
+	if dbKey.RevokedAt.Valid {
+		return uuid.Nil, errors.New("API key has been revoked")
+	}
+	if dbKey.ExpiresAt.Valid && time.Now().UTC().After(dbKey.ExpiresAt.Time) {
+		return uuid.Nil, errors.New("API key has expired")
+	}
+	if !slices.Contains(dbKey.Scopes, scope) {
+		return uuid.Nil, errMissingScope
+	}
+
+	err = cfg.db.TouchAPIKey(r.Context(), dbKey.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return dbKey.UserID, nil
+}
+
+// requireAdmin checks for "Authorization: ApiKey <ADMIN_KEY>". The admin API
+// is disabled when no key is configured.
+func (cfg *apiConfig) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.adminKey == "" {
+		respondWithError(w, r, http.StatusForbidden, "Admin API is disabled", nil)
+		return false
+	}
+
+	key, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find API key", err)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminKey)) != 1 {
+		respondWithError(w, r, http.StatusUnauthorized, "API key is invalid", nil)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// planFree applies to users without a subscription in its paid period
+const planFree = "free"
+
+// chirpRateWindow is the window Entitlements.ChirpsPerHour is counted over
+const chirpRateWindow = time.Hour
+
+// Entitlements are what a plan lets a user do. They're looked up per request
+// so that a change in subscription applies straight away.
+type Entitlements struct {
+	MaxChirpLength int    `json:"max_chirp_length"`
+	CanEditChirps  bool   `json:"can_edit_chirps"`
+	ChirpsPerHour  int    `json:"chirps_per_hour"`
+	Badge          string `json:"badge,omitempty"`
+}
+
+var defaultEntitlements = map[string]Entitlements{
+	planFree: {
+		MaxChirpLength: 140,
+		ChirpsPerHour:  30,
+	},
+	planChirpyRed: {
+		MaxChirpLength: 1000,
+		CanEditChirps:  true,
+		ChirpsPerHour:  300,
+		Badge:          "red",
+	},
+}
+
+// loadEntitlements reads plan entitlements from a JSON object keyed by plan.
+// Plans in the file replace the defaults; others keep them.
+func loadEntitlements(path string) (map[string]Entitlements, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plans map[string]Entitlements
+	if err := json.Unmarshal(data, &plans); err != nil {
+		return nil, err
+	}
+
+	entitlements := map[string]Entitlements{}
+	for plan, e := range defaultEntitlements {
+		entitlements[plan] = e
+	}
+	for plan, e := range plans {
+		if e.MaxChirpLength <= 0 {
+			return nil, fmt.Errorf("plan %q: max_chirp_length must be positive", plan)
+		}
+		if e.ChirpsPerHour <= 0 {
+			return nil, fmt.Errorf("plan %q: chirps_per_hour must be positive", plan)
+		}
+		entitlements[plan] = e
+	}
+	return entitlements, nil
+}
+
+// entitlementsFor returns the user's current plan and what it allows. A plan
+// missing from the config gets the free entitlements, so a misconfiguration
+// can't grant more than intended.
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (string, Entitlements, error) {
+	plan, err := cfg.store.GetActivePlan(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		plan = planFree
+	} else if err != nil {
+		return "", Entitlements{}, err
+	}
+
+	e, ok := cfg.entitlements[plan]
+	if !ok {
+		slog.WarnContext(ctx, "No entitlements configured for plan, using free", "plan", plan)
+		e = cfg.entitlements[planFree]
+	}
+	return plan, e, nil
+}
+
+func (cfg *apiConfig) handlerGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Plan string `json:"plan"`
+		Entitlements
+	}
+
+	// Bots posting chirps need their limits, so chirps:write is enough
+	userID, ok := cfg.requireUser(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	plan, e, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get entitlements", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Plan:         plan,
+		Entitlements: e,
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadEntitlements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entitlements.json")
+	data := `{"chirpy_red": {"max_chirp_length": 500, "can_edit_chirps": true, "chirps_per_hour": 100, "badge": "ruby"}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	entitlements, err := loadEntitlements(path)
+	if err != nil {
+		t.Fatalf("loadEntitlements() returned an error: %v", err)
+	}
+
+	red := entitlements[planChirpyRed]
+	if red.MaxChirpLength != 500 || red.ChirpsPerHour != 100 || red.Badge != "ruby" {
+		t.Errorf("chirpy_red = %+v, expected the values from the file", red)
+	}
+	if entitlements[planFree] != defaultEntitlements[planFree] {
+		t.Errorf("free = %+v, expected the defaults", entitlements[planFree])
+	}
+}
+
+func TestLoadEntitlementsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"zero max_chirp_length": `{"free": {"max_chirp_length": 0, "chirps_per_hour": 10}}`,
+		"zero chirps_per_hour":  `{"free": {"max_chirp_length": 140, "chirps_per_hour": 0}}`,
+	}
+	for name, data := range tests {
+		path := filepath.Join(t.TempDir(), "entitlements.json")
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		if _, err := loadEntitlements(path); err == nil {
+			t.Errorf("loadEntitlements() should reject a %s", name)
+		}
+	}
+}
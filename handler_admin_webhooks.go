@@ -0,0 +1,147 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWebhookListLimit = 50
+	maxWebhookListLimit     = 500
+)
+
+type WebhookEvent struct {
+	ID           string          `json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Source       string          `json:"source"`
+	Event        string          `json:"event"`
+	Headers      json.RawMessage `json:"headers"`
+	Body         json.RawMessage `json:"body"`
+	Status       string          `json:"status"`
+	Attempts     int32           `json:"attempts"`
+	ResponseCode *int32          `json:"response_code"`
+	Error        *string         `json:"error"`
+	ProcessingMs *int32          `json:"processing_ms"`
+	ProcessedAt  *time.Time      `json:"processed_at"`
+}
+
+func fromDbWebhookEvent(e *database.WebhookEvent) *WebhookEvent {
+	event := &WebhookEvent{
+		ID:          e.ID,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		Source:      e.Source,
+		Event:       e.Event,
+		Headers:     e.Headers,
+		Body:        e.Body,
+		Status:      e.Status,
+		Attempts:    e.Attempts,
+		ProcessedAt: nullTimePtr(e.ProcessedAt),
+	}
+	if e.ResponseCode.Valid {
+		event.ResponseCode = &e.ResponseCode.Int32
+	}
+	if e.Error.Valid {
+		event.Error = &e.Error.String
+	}
+	if e.ProcessingMs.Valid {
+		event.ProcessingMs = &e.ProcessingMs.Int32
+	}
+	return event
+}
+
+func (cfg *apiConfig) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	limit := defaultWebhookListLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxWebhookListLimit {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	dbEvents, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:   r.URL.Query().Get("status"),
+		RowLimit: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list webhook events", err)
+		return
+	}
+
+	events := make([]*WebhookEvent, 0, len(dbEvents))
+	for i := range dbEvents {
+		events = append(events, fromDbWebhookEvent(&dbEvents[i]))
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	dbEvent, err := cfg.db.GetWebhookEvent(r.Context(), r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find webhook event", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, fromDbWebhookEvent(&dbEvent))
+}
+
+// handlerReplayWebhookEvent runs a stored event through the same logic as a
+// live delivery, without checking the signature again since it was checked
+// when the event arrived.
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireAdmin(w, r) {
+		return
+	}
+
+	eventID := r.PathValue("eventID")
+	dbEvent, err := cfg.db.RetryWebhookEvent(r.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = cfg.db.GetWebhookEvent(r.Context(), eventID)
+		if err != nil {
+			respondWithError(w, r, http.StatusNotFound, "Couldn't find webhook event", err)
+			return
+		}
+		respondWithError(w, r, http.StatusConflict, "Webhook event is being processed", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't claim webhook event", err)
+		return
+	}
+
+	params := polkaWebhook{}
+	err = json.Unmarshal(dbEvent.Body, &params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode stored webhook", err)
+		return
+	}
+
+	// The outcome is recorded on the event, so a failed replay is still a
+	// successful request
+	cfg.processPolkaWebhook(r.Context(), &params)
+
+	dbEvent, err = cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get webhook event", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, fromDbWebhookEvent(&dbEvent))
+}
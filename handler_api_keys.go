@@ -0,0 +1,197 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func fromDbAPIKey(k *database.ApiKey) *APIKey {
+	return &APIKey{
+		ID:         k.ID,
+		CreatedAt:  k.CreatedAt,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		ExpiresAt:  nullTimePtr(k.ExpiresAt),
+		LastUsedAt: nullTimePtr(k.LastUsedAt),
+		RevokedAt:  nullTimePtr(k.RevokedAt),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (cfg *apiConfig) handlerCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	type response struct {
+		APIKey
+		Key string `json:"key"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Name == "" {
+		respondWithError(w, r, http.StatusBadRequest, "API key name is required", nil)
+		return
+	}
+	if len(params.Scopes) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(validScopes, scope) {
+			respondWithError(w, r, http.StatusBadRequest, "Unknown scope: "+scope, nil)
+			return
+		}
+	}
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, r, http.StatusBadRequest, "expires_in_seconds can't be negative", nil)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second),
+			Valid: true,
+		}
+	}
+
+	prefix, key, err := auth.MakeAPIKey()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create API key", err)
+		return
+	}
+
+	hash, err := auth.HashPassword(key)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash API key", err)
+		return
+	}
+
+	dbKey, err := cfg.db.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      params.Name,
+		Prefix:    prefix,
+		HashedKey: hash,
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save API key", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		APIKey: *fromDbAPIKey(&dbKey),
+		Key:    key,
+	})
+}
+
+func (cfg *apiConfig) handlerListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	dbKeys, err := cfg.db.ListAPIKeysForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error fetching API keys", err)
+		return
+	}
+
+	keys := []*APIKey{}
+	for _, key := range dbKeys {
+		keys = append(keys, fromDbAPIKey(&key))
+	}
+
+	respondWithJSON(w, http.StatusOK, &keys)
+}
+
+func (cfg *apiConfig) handlerRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyIDString := r.PathValue("keyID")
+	keyID, err := uuid.Parse(keyIDString)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid API key ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	_, err = cfg.db.RevokeAPIKey(r.Context(), database.RevokeAPIKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Couldn't find API key", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke API key", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
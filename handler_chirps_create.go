@@ -1,11 +1,12 @@
 package main
 
 import (
-	"chirpy/internal/auth"
 	"chirpy/internal/database"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,47 +36,58 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		Body string `json:"body"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+	userID, ok := cfg.requireUser(w, r, scopeChirpsWrite)
+	if !ok {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	_, e, err := cfg.entitlementsFor(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get entitlements", err)
 		return
 	}
 
-	cleaned, err := validateChirp(params.Body)
+	cleaned, err := validateChirp(params.Body, e.MaxChirpLength)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	dbChirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
+	allowed, retryAfter := cfg.chirpLimiter.allow(userID.String(), e.ChirpsPerHour)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondWithError(w, r, http.StatusTooManyRequests, "Too many chirps, try again later", nil)
+		return
+	}
+
+	dbChirp, err := cfg.store.CreateChirp(r.Context(), database.CreateChirpParams{
 		Body:   cleaned,
 		UserID: userID,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, fromDbChirp(&dbChirp))
+	cfg.metrics.chirpsCreated.Inc()
+
+	chirp := fromDbChirp(&dbChirp)
+	cfg.enqueueWebhookEvent(r.Context(), userID, webhookEventChirpCreated, chirp)
+	cfg.enqueueMentionEvents(r.Context(), chirp)
+
+	respondWithJSON(w, http.StatusCreated, chirp)
 }
 
-func validateChirp(body string) (string, error) {
-	const maxChirpLength = 140
-	if len(body) > maxChirpLength {
+func validateChirp(body string, maxLength int) (string, error) {
+	if len(body) > maxLength {
 		return "", errors.New("Chirp is too long")
 	}
 
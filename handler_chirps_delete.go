@@ -1,7 +1,6 @@
 package main
 
 import (
-	"chirpy/internal/auth"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -11,37 +10,32 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	userID, ok := cfg.requireUser(w, r, scopeChirpsWrite)
+	if !ok {
 		return
 	}
 
-	dbChirp, err := cfg.db.DetailChirp(r.Context(), chirpID)
+	dbChirp, err := cfg.store.DetailChirp(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find chirp", err)
 		return
 	}
 	if dbChirp.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "You can't delete this chirp", err)
+		respondWithError(w, r, http.StatusForbidden, "You can't delete this chirp", err)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), chirpID)
+	err = cfg.store.DeleteChirp(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
 
+	cfg.enqueueWebhookEvent(r.Context(), userID, webhookEventChirpDeleted, fromDbChirp(&dbChirp))
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -10,13 +10,13 @@ func (cfg *apiConfig) handlerDetailChirp(w http.ResponseWriter, r *http.Request)
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
 		return
 	}
 
-	dbChirp, err := cfg.db.DetailChirp(r.Context(), chirpID)
+	dbChirp, err := cfg.store.DetailChirp(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find chirp", err)
 		return
 	}
 
@@ -8,9 +8,9 @@ import (
 )
 
 func (cfg *apiConfig) handlerListChirps(w http.ResponseWriter, r *http.Request) {
-	dbChirps, err := cfg.db.ListChirps(r.Context())
+	dbChirps, err := cfg.store.ListChirps(r.Context())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error fetching chirps", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error fetching chirps", err)
 		return
 	}
 
@@ -19,7 +19,7 @@ func (cfg *apiConfig) handlerListChirps(w http.ResponseWriter, r *http.Request)
 	if authorIDString != "" {
 		authorID, err = uuid.Parse(authorIDString)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			respondWithError(w, r, http.StatusBadRequest, "Invalid author ID", err)
 			return
 		}
 	}
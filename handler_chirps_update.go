@@ -0,0 +1,75 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	chirpIDString := r.PathValue("chirpID")
+	chirpID, err := uuid.Parse(chirpIDString)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	dbChirp, err := cfg.store.DetailChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+	if dbChirp.UserID != userID {
+		respondWithError(w, r, http.StatusForbidden, "You can't edit this chirp", nil)
+		return
+	}
+
+	_, e, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get entitlements", err)
+		return
+	}
+	if !e.CanEditChirps {
+		respondWithError(w, r, http.StatusForbidden, "Your plan doesn't include editing chirps", nil)
+		return
+	}
+
+	cleaned, err := validateChirp(params.Body, e.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirp, err = cfg.store.UpdateChirp(r.Context(), database.UpdateChirpParams{
+		ID:   chirpID,
+		Body: cleaned,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	chirp := fromDbChirp(&dbChirp)
+	cfg.enqueueWebhookEvent(r.Context(), userID, webhookEventChirpUpdated, chirp)
+
+	respondWithJSON(w, http.StatusOK, chirp)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	emailVerificationLifetime = 24 * time.Hour
+
+	// Resends allowed per window for one user, so the endpoint can't be
+	// used to flood an inbox or the mail server
+	emailVerificationResendLimit  = 3
+	emailVerificationResendWindow = time.Hour
+)
+
+// sendEmailVerification emails a single-use link that proves the user can
+// read mail sent to their current address.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, dbUser *database.User) error {
+	// Verification tokens are only stored in Postgres
+	if cfg.db == nil {
+		return nil
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    dbUser.ID,
+		Email:     dbUser.Email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Please confirm this is your email address by opening the link below "+
+				"within the next 24 hours:\n\n%s\n",
+			cfg.baseURL+"/api/users/verify-email?token="+url.QueryEscape(token),
+		),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	dbToken, err := cfg.db.UseEmailVerificationToken(r.Context(), auth.HashToken(r.URL.Query().Get("token")))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Verification link is invalid or has expired", err)
+		return
+	}
+
+	// The update only matches if the user hasn't changed their email since
+	// the link was sent
+	dbUser, err := cfg.store.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    dbToken.UserID,
+		Email: dbToken.Email,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Verification link is for an old email address", err)
+		return
+	}
+
+	err = cfg.db.DeleteEmailVerificationTokensForUser(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete verification tokens", err)
+		return
+	}
+
+	cfg.respondWithUser(w, r, http.StatusOK, &dbUser)
+}
+
+func (cfg *apiConfig) handlerResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	dbUser, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if dbUser.EmailVerifiedAt.Valid {
+		respondWithError(w, r, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+
+	allowed, retryAfter := cfg.verificationLimiter.allow(dbUser.ID.String(), emailVerificationResendLimit)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondWithError(w, r, http.StatusTooManyRequests, "Too many verification emails, please try again later", nil)
+		return
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), &dbUser)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
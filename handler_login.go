@@ -3,7 +3,9 @@ package main
 import (
 	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 )
@@ -13,62 +15,77 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	dbUser, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
+	dbUser, err := cfg.verifyLogin(r, params.Email, params.Password)
+	cfg.metrics.logins.WithLabelValues(loginResult(err)).Inc()
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		respondWithLoginError(w, r, err)
 		return
 	}
 
-	valid, err := auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't check password hash", err)
-		return
+	// Two-factor authentication is only available with Postgres
+	if cfg.db != nil {
+		dbMFA, err := cfg.db.GetUserMFA(r.Context(), dbUser.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+			return
+		}
+		if err == nil && dbMFA.EnabledAt.Valid {
+			cfg.respondWithMFAChallenge(w, r, dbUser.ID)
+			return
+		}
+	}
+
+	cfg.respondWithSession(w, r, &dbUser)
+}
+
+// respondWithSession issues a new access and refresh token pair for a user
+// who has fully authenticated.
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, r *http.Request, dbUser *database.User) {
+	type response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
-	if !valid {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+	user, err := cfg.userFromDb(r.Context(), dbUser)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get subscription", err)
 		return
 	}
 
-	expiresIn := time.Hour
-	accessToken, err := auth.MakeJWT(dbUser.ID, cfg.jwtSecret, expiresIn)
+	accessToken, err := auth.MakeJWT(dbUser.ID, cfg.jwtSecret, cfg.accessTokenLifetime)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't create access JWT", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't create access JWT", err)
 		return
 	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't create refresh token", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't create refresh token", err)
 		return
 	}
 
-	dbRefreshToken, err := cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+	dbRefreshToken, err := cfg.store.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		Token:     refreshToken,
 		UserID:    dbUser.ID,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
+		ExpiresAt: time.Now().UTC().Add(cfg.refreshTokenLifetime),
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save refresh token", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, response{
-		User:         *fromDbUser(&dbUser),
+		User:         *user,
 		Token:        accessToken,
 		RefreshToken: dbRefreshToken.Token,
 	})
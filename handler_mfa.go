@@ -0,0 +1,379 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	mfaIssuer             = "Chirpy"
+	mfaChallengeLifetime  = 5 * time.Minute
+	mfaRecoveryCodeCount  = 10
+	mfaRecoveryCodeLength = 8
+)
+
+var errInvalidMFACode = errors.New("invalid two-factor code")
+
+func (cfg *apiConfig) respondWithMFAChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	type response struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+
+	challenge, err := auth.MakeMFAChallengeJWT(userID, cfg.jwtSecret, mfaChallengeLifetime)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create MFA challenge", err)
+		return
+	}
+
+	// The challenge is recorded so it can only complete one login
+	err = cfg.db.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		TokenHash: auth.HashToken(challenge),
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(mfaChallengeLifetime),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save MFA challenge", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		MFARequired: true,
+		MFAToken:    challenge,
+	})
+}
+
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	userID, err := auth.ValidateMFAChallengeJWT(params.MFAToken, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate MFA token", err)
+		return
+	}
+
+	// Share the login IP throttle so codes can't be brute forced either
+	ip := clientIP(r)
+	failed, retryAfter := cfg.loginLimiter.count(ip)
+	if failed >= ipFailedLoginLimit {
+		respondWithLoginError(w, r, &loginThrottledError{retryAfter: retryAfter})
+		return
+	}
+
+	// Claim the challenge before checking the code, so a replayed token
+	// can't use up a recovery code or a TOTP step. A wrong code means
+	// logging in again for a new challenge.
+	_, err = cfg.db.UseMFAChallenge(r.Context(), auth.HashToken(params.MFAToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusUnauthorized, "MFA token has already been used", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't use MFA token", err)
+		return
+	}
+
+	err = cfg.verifySecondFactor(r.Context(), userID, params.Code, params.RecoveryCode)
+	if err != nil {
+		cfg.loginLimiter.hit(ip)
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect two-factor code", err)
+		return
+	}
+
+	dbUser, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+
+	cfg.respondWithSession(w, r, &dbUser)
+}
+
+func (cfg *apiConfig) handlerEnrollMFA(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	dbUser, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      mfaIssuer,
+		AccountName: dbUser.Email,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't generate secret", err)
+		return
+	}
+
+	encryptedSecret, err := auth.EncryptSecret(key.Secret(), cfg.mfaEncryptionKey)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't encrypt secret", err)
+		return
+	}
+
+	_, err = cfg.db.StartUserMFAEnrollment(r.Context(), database.StartUserMFAEnrollmentParams{
+		UserID:          userID,
+		EncryptedSecret: encryptedSecret,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusConflict, "Two-factor authentication is already enabled", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save secret", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	dbMFA, err := cfg.db.GetUserMFA(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Two-factor enrollment hasn't been started", err)
+		return
+	}
+	if dbMFA.EnabledAt.Valid {
+		respondWithError(w, r, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	err = cfg.validateTOTP(r.Context(), &dbMFA, params.Code)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect two-factor code", err)
+		return
+	}
+
+	recoveryCodes, err := cfg.replaceRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+
+	err = cfg.db.EnableUserMFA(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// handlerDisableMFA requires the password and a second factor even though
+// the caller already holds an access token, so a stolen token alone can't
+// strip 2FA from an account.
+func (cfg *apiConfig) handlerDisableMFA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	dbUser, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	// Checked like a login, so a stolen access token can't be used to
+	// guess the password without hitting the throttle and lockout
+	_, err = cfg.verifyLogin(r, dbUser.Email, params.Password)
+	if err != nil {
+		if errors.Is(err, errIncorrectLogin) {
+			respondWithError(w, r, http.StatusUnauthorized, "Incorrect password", err)
+			return
+		}
+		respondWithLoginError(w, r, err)
+		return
+	}
+
+	err = cfg.verifySecondFactor(r.Context(), userID, params.Code, params.RecoveryCode)
+	if err != nil {
+		cfg.loginLimiter.hit(clientIP(r))
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect two-factor code", err)
+		return
+	}
+
+	err = cfg.db.DeleteMFARecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete recovery codes", err)
+		return
+	}
+
+	err = cfg.db.DeleteUserMFA(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code, which is consumed.
+func (cfg *apiConfig) verifySecondFactor(ctx context.Context, userID uuid.UUID, code, recoveryCode string) error {
+	dbMFA, err := cfg.db.GetUserMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !dbMFA.EnabledAt.Valid {
+		return errors.New("two-factor authentication isn't enabled")
+	}
+
+	if recoveryCode != "" {
+		_, err = cfg.db.UseMFARecoveryCode(ctx, database.UseMFARecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(recoveryCode),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidMFACode
+		}
+		return err
+	}
+
+	return cfg.validateTOTP(ctx, &dbMFA, code)
+}
+
+// validateTOTP accepts a TOTP code at most once. Recording the code's time
+// step only if it's newer than the last one accepted stops a code from being
+// replayed within its window, including by a concurrent request.
+func (cfg *apiConfig) validateTOTP(ctx context.Context, dbMFA *database.UserMfa, code string) error {
+	secret, err := auth.DecryptSecret(dbMFA.EncryptedSecret, cfg.mfaEncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	step, ok := auth.ValidateTOTP(code, secret, time.Now())
+	if !ok {
+		return errInvalidMFACode
+	}
+
+	_, err = cfg.db.RecordTOTPStep(ctx, database.RecordTOTPStepParams{
+		UserID:       dbMFA.UserID,
+		LastTotpStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidMFACode
+	}
+	return err
+}
+
+func (cfg *apiConfig) replaceRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	err := cfg.db.DeleteMFARecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	for range mfaRecoveryCodeCount {
+		b := make([]byte, mfaRecoveryCodeLength)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+
+		err = cfg.db.CreateMFARecoveryCode(ctx, database.CreateMFARecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+const oauthCodeLifetime = 5 * time.Minute
+
+var consentTemplate = template.Must(template.New("consent").Parse(`
+<html>
+  <body>
+	<h1>Authorize {{.ClientName}}</h1>
+	<p>{{.ClientName}} wants to access your Chirpy account.</p>
+	{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+	<form method="POST" action="/oauth/authorize">
+	  <input type="hidden" name="response_type" value="code">
+	  <input type="hidden" name="client_id" value="{{.ClientID}}">
+	  <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+	  <input type="hidden" name="state" value="{{.State}}">
+	  <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+	  <input type="hidden" name="code_challenge_method" value="{{.CodeChallengeMethod}}">
+	  <p><label>Email <input type="email" name="email"></label></p>
+	  <p><label>Password <input type="password" name="password"></label></p>
+	  <p><label>Two-factor or recovery code, if enabled <input type="text" name="code" autocomplete="one-time-code"></label></p>
+	  <button type="submit" name="action" value="approve">Allow</button>
+	  <button type="submit" name="action" value="deny">Deny</button>
+	</form>
+  </body>
+</html>
+`))
+
+type authorizeRequest struct {
+	ClientID            string
+	ClientName          string
+	RedirectURI         string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Error               string
+}
+
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, ok := cfg.parseAuthorizeRequest(w, r, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	renderConsent(w, http.StatusOK, req)
+}
+
+func (cfg *apiConfig) handlerOAuthAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't parse form", err)
+		return
+	}
+
+	req, ok := cfg.parseAuthorizeRequest(w, r, r.PostForm)
+	if !ok {
+		return
+	}
+
+	if r.PostForm.Get("action") != "approve" {
+		redirectWithOAuthError(w, r, req, "access_denied")
+		return
+	}
+
+	dbUser, err := cfg.verifyLogin(r, r.PostForm.Get("email"), r.PostForm.Get("password"))
+	if err != nil {
+		var throttled *loginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			req.Error = "Too many failed login attempts, please try again later"
+			renderConsent(w, http.StatusTooManyRequests, req)
+		case errors.Is(err, errIncorrectLogin):
+			req.Error = "Incorrect email or password"
+			renderConsent(w, http.StatusUnauthorized, req)
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't log in", err)
+		}
+		return
+	}
+
+	// Consent issues tokens just like a login, so it needs the second
+	// factor too
+	dbMFA, err := cfg.db.GetUserMFA(r.Context(), dbUser.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+	if err == nil && dbMFA.EnabledAt.Valid {
+		mfaCode := r.PostForm.Get("code")
+		err = cfg.verifySecondFactor(r.Context(), dbUser.ID, mfaCode, "")
+		if errors.Is(err, errInvalidMFACode) {
+			err = cfg.verifySecondFactor(r.Context(), dbUser.ID, "", mfaCode)
+		}
+		if err != nil {
+			cfg.loginLimiter.hit(clientIP(r))
+			req.Error = "Incorrect two-factor code"
+			renderConsent(w, http.StatusUnauthorized, req)
+			return
+		}
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create authorization code", err)
+		return
+	}
+
+	err = cfg.db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.ClientID,
+		UserID:        dbUser.ID,
+		RedirectUri:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(oauthCodeLifetime),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save authorization code", err)
+		return
+	}
+
+	redirectTo, _ := url.Parse(req.RedirectURI)
+	query := redirectTo.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectTo.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirectTo.String(), http.StatusFound)
+}
+
+// parseAuthorizeRequest validates the client and redirect URI first so that
+// we never redirect to an unregistered location; later problems are reported
+// back to the client through the redirect.
+func (cfg *apiConfig) parseAuthorizeRequest(w http.ResponseWriter, r *http.Request, values url.Values) (authorizeRequest, bool) {
+	req := authorizeRequest{
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		State:               values.Get("state"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+
+	dbClient, err := cfg.db.GetOAuthClient(r.Context(), req.ClientID)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Unknown client", err)
+		return req, false
+	}
+	if !slices.Contains(dbClient.RedirectUris, req.RedirectURI) {
+		respondWithError(w, r, http.StatusBadRequest, "Redirect URI isn't registered for this client", nil)
+		return req, false
+	}
+	req.ClientName = dbClient.Name
+
+	if values.Get("response_type") != "code" {
+		redirectWithOAuthError(w, r, req, "unsupported_response_type")
+		return req, false
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != auth.PKCEMethodS256 {
+		redirectWithOAuthError(w, r, req, "invalid_request")
+		return req, false
+	}
+
+	return req, true
+}
+
+func redirectWithOAuthError(w http.ResponseWriter, r *http.Request, req authorizeRequest, code string) {
+	redirectTo, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid redirect URI", errors.New(code))
+		return
+	}
+
+	query := redirectTo.Query()
+	query.Set("error", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectTo.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirectTo.String(), http.StatusFound)
+}
+
+func renderConsent(w http.ResponseWriter, code int, req authorizeRequest) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Keep the consent page out of third-party frames to prevent clickjacking
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(code)
+	consentTemplate.Execute(w, req)
+}
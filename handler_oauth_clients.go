@@ -0,0 +1,165 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+}
+
+func fromDbOAuthClient(c *database.OauthClient) *OAuthClient {
+	return &OAuthClient{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectUris,
+		Confidential: c.HashedSecret.Valid,
+	}
+}
+
+func (cfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+	type response struct {
+		OAuthClient
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Name == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Client name is required", nil)
+		return
+	}
+	if len(params.RedirectURIs) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "At least one redirect URI is required", nil)
+		return
+	}
+	for _, redirectURI := range params.RedirectURIs {
+		err = validateRedirectURI(redirectURI)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	clientSecret := ""
+	hashedSecret := sql.NullString{}
+	if params.Confidential {
+		clientSecret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't create client secret", err)
+			return
+		}
+
+		hash, err := auth.HashPassword(clientSecret)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash client secret", err)
+			return
+		}
+		hashedSecret = sql.NullString{String: hash, Valid: true}
+	}
+
+	dbClient, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.NewString(),
+		UserID:       userID,
+		Name:         params.Name,
+		HashedSecret: hashedSecret,
+		RedirectUris: params.RedirectURIs,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create client", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		OAuthClient:  *fromDbOAuthClient(&dbClient),
+		ClientSecret: clientSecret,
+	})
+}
+
+func (cfg *apiConfig) handlerListOAuthClients(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	dbClients, err := cfg.db.ListOAuthClientsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error fetching clients", err)
+		return
+	}
+
+	clients := []*OAuthClient{}
+	for _, client := range dbClients {
+		clients = append(clients, fromDbOAuthClient(&client))
+	}
+
+	respondWithJSON(w, http.StatusOK, &clients)
+}
+
+// validateRedirectURI only allows absolute https URIs, plus plain http for
+// loopback addresses used by native and development clients.
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.New("Redirect URI must be an absolute URL")
+	}
+	if u.Fragment != "" {
+		return errors.New("Redirect URI can't contain a fragment")
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			return nil
+		}
+	}
+
+	return errors.New("Redirect URI must use https")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_request", err)
+		return
+	}
+
+	dbClient, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusUnauthorized, "invalid_client", err)
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, dbClient)
+	case "refresh_token":
+		cfg.exchangeOAuthRefreshToken(w, r, dbClient)
+	default:
+		respondWithOAuthError(w, r, http.StatusBadRequest, "unsupported_grant_type", nil)
+	}
+}
+
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, dbClient database.OauthClient) {
+	dbCode, err := cfg.db.UseOAuthAuthorizationCode(r.Context(), auth.HashToken(r.PostForm.Get("code")))
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", err)
+		return
+	}
+	if dbCode.ClientID != dbClient.ID || dbCode.RedirectUri != r.PostForm.Get("redirect_uri") {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", errors.New("authorization code was issued for another client or redirect URI"))
+		return
+	}
+
+	err = auth.VerifyCodeChallenge(r.PostForm.Get("code_verifier"), dbCode.CodeChallenge)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", err)
+		return
+	}
+
+	cfg.issueOAuthTokens(w, r, dbClient, dbCode.UserID)
+}
+
+func (cfg *apiConfig) exchangeOAuthRefreshToken(w http.ResponseWriter, r *http.Request, dbClient database.OauthClient) {
+	clientID := sql.NullString{String: dbClient.ID, Valid: true}
+	token := r.PostForm.Get("refresh_token")
+
+	// Refresh tokens are rotated on every use. Revoking and reading the
+	// token in one statement means only one of two concurrent refreshes
+	// gets new tokens.
+	userID, err := cfg.db.RotateOAuthRefreshToken(r.Context(), database.RotateOAuthRefreshTokenParams{
+		Token:    token,
+		ClientID: clientID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.detectOAuthRefreshTokenReuse(r, token, clientID)
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_grant", err)
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+
+	cfg.issueOAuthTokens(w, r, dbClient, userID)
+}
+
+// detectOAuthRefreshTokenReuse revokes the user's whole grant to the client
+// when a refresh token that was already rotated or revoked is presented
+// again, since either the client or an attacker holds a stolen copy.
+func (cfg *apiConfig) detectOAuthRefreshTokenReuse(r *http.Request, token string, clientID sql.NullString) {
+	dbToken, err := cfg.db.GetRevokedOAuthRefreshToken(r.Context(), database.GetRevokedOAuthRefreshTokenParams{
+		Token:    token,
+		ClientID: clientID,
+	})
+	if err != nil {
+		return
+	}
+
+	slog.WarnContext(r.Context(), "Revoked OAuth refresh token reused, revoking the grant", "user_id", dbToken.UserID, "client_id", clientID.String)
+	err = cfg.db.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{
+		UserID:   dbToken.UserID,
+		ClientID: clientID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't revoke OAuth grant", "error", err)
+	}
+}
+
+func (cfg *apiConfig) issueOAuthTokens(w http.ResponseWriter, r *http.Request, dbClient database.OauthClient, userID uuid.UUID) {
+	type response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	accessToken, err := auth.MakeJWT(userID, cfg.jwtSecret, cfg.accessTokenLifetime)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+
+	_, err = cfg.db.CreateOAuthRefreshToken(r.Context(), database.CreateOAuthRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(cfg.refreshTokenLifetime),
+		ClientID:  sql.NullString{String: dbClient.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.accessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusBadRequest, "invalid_request", err)
+		return
+	}
+
+	dbClient, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusUnauthorized, "invalid_client", err)
+		return
+	}
+
+	// Per RFC 7009 unknown tokens are not an error, so only a database
+	// failure is reported back to the client.
+	err = cfg.db.RevokeOAuthRefreshToken(r.Context(), database.RevokeOAuthRefreshTokenParams{
+		Token:    r.PostForm.Get("token"),
+		ClientID: sql.NullString{String: dbClient.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithOAuthError(w, r, http.StatusInternalServerError, "server_error", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateOAuthClient accepts client credentials via HTTP Basic auth or
+// the request body. Public clients only need to identify themselves.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	dbClient, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+
+	if dbClient.HashedSecret.Valid {
+		match, err := auth.CheckPasswordHash(clientSecret, dbClient.HashedSecret.String)
+		if err != nil {
+			return database.OauthClient{}, err
+		}
+		if !match {
+			return database.OauthClient{}, errors.New("client secret doesn't match")
+		}
+	}
+
+	return dbClient, nil
+}
+
+// respondWithOAuthError uses the error format from RFC 6749 section 5.2
+// rather than our usual error body, since OAuth client libraries expect it.
+func respondWithOAuthError(w http.ResponseWriter, r *http.Request, code int, oauthError string, err error) {
+	requestID := requestIDFromContext(r.Context())
+	if code > 499 {
+		slog.ErrorContext(r.Context(), oauthError, "status", code, "error", err)
+	} else if err != nil {
+		slog.InfoContext(r.Context(), oauthError, "status", code, "error", err)
+	}
+	type errorResponse struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, errorResponse{
+		Error:     oauthError,
+		RequestID: requestID,
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	passwordResetLifetime = time.Hour
+
+	// Reset requests allowed per window from one IP and for one email, so
+	// the endpoint can't be used to flood an inbox or the mail server
+	passwordResetIPLimit    = 10
+	passwordResetEmailLimit = 3
+	passwordResetWindow     = time.Hour
+
+	// passwordResetSendTimeout bounds the lookup and mail sent after the
+	// request has been answered
+	passwordResetSendTimeout = 30 * time.Second
+)
+
+func (cfg *apiConfig) handlerRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	for key, limit := range map[string]int{
+		"ip:" + clientIP(r): passwordResetIPLimit,
+		"email:" + strings.ToLower(strings.TrimSpace(params.Email)): passwordResetEmailLimit,
+	} {
+		allowed, retryAfter := cfg.resetLimiter.allow(key, limit)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respondWithError(w, r, http.StatusTooManyRequests, "Too many password reset requests, please try again later", nil)
+			return
+		}
+	}
+
+	// Respond before looking the account up, so neither the status nor the
+	// response time reveals whether the email is registered.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), passwordResetSendTimeout)
+	cfg.background.Go(func() {
+		defer cancel()
+		err := cfg.sendPasswordReset(ctx, params.Email)
+		if err != nil {
+			slog.ErrorContext(ctx, "Couldn't send password reset email", "error", err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordReset mails a reset link if the email belongs to an account
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	dbUser, err := cfg.store.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    dbUser.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your Chirpy account.\n\n"+
+				"Use this link within the next hour to choose a new password:\n\n%s\n\n"+
+				"If this wasn't you, you can ignore this email.\n",
+			cfg.baseURL+"/reset-password?token="+url.QueryEscape(token),
+		),
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Check the password before using up the token so the user can retry
+	if !cfg.checkPassword(w, r, params.Password) {
+		return
+	}
+
+	dbToken, err := cfg.db.UsePasswordResetToken(r.Context(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Reset token is invalid or has expired", err)
+		return
+	}
+
+	hash, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash password", err)
+		return
+	}
+
+	err = cfg.store.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             dbToken.UserID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update password", err)
+		return
+	}
+
+	// Whoever knew the old password may still hold a session
+	err = cfg.store.RevokeAllRefreshTokensForUser(r.Context(), dbToken.UserID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	err = cfg.db.DeletePasswordResetTokensForUser(r.Context(), dbToken.UserID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete reset tokens", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
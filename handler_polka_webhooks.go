@@ -2,56 +2,161 @@ package main
 
 import (
 	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"time"
+)
+
+const (
+	// Deliveries signed further than this from our clock are rejected
+	polkaWebhookTolerance = 5 * time.Minute
+
+	maxWebhookBodyBytes = 1 << 20
+)
+
+const (
+	webhookSourcePolka = "polka"
 
-	"github.com/google/uuid"
+	webhookProcessing = "processing"
+	webhookProcessed  = "processed"
+	webhookFailed     = "failed"
 )
 
+type polkaWebhook struct {
+	ID    string         `json:"id"`
+	Event string         `json:"event"`
+	Data  polkaEventData `json:"data"`
+}
+
+// handlerPolkaWebhooks stores every authentic delivery in webhook_events
+// before acting on it. Deliveries with a bad signature aren't stored, so
+// anyone on the internet can't fill the table.
 func (cfg *apiConfig) handlerPolkaWebhooks(w http.ResponseWriter, r *http.Request) {
-	type PolkaData struct {
-		UserID uuid.UUID `json:"user_id"`
-	}
-	type parameters struct {
-		Event string    `json:"event"`
-		Data  PolkaData `json:"data"`
+	// The signature covers the exact bytes sent, so read them before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't read body", err)
+		return
 	}
 
-	apiKey, err := auth.GetAPIKey(r.Header)
+	err = auth.VerifyWebhookSignature(r.Header, body, cfg.polkaKeys, time.Now(), polkaWebhookTolerance)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find API key", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Webhook signature is invalid", err)
 		return
 	}
 
-	if cfg.polkaKey != apiKey {
-		respondWithError(w, http.StatusUnauthorized, "API key is invalid", err)
+	params := polkaWebhook{}
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+	if params.ID == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Missing event ID", nil)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	headers := r.Header.Clone()
+	headers.Del("Authorization")
+	headers.Del("Cookie")
+	headersJSON, err := json.Marshal(headers)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't encode headers", err)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
+	// Only new or previously failed events can be claimed, so an event
+	// that was already handled is acknowledged without being processed
+	// again. A genuine retry stops and a replay does nothing.
+	_, err = cfg.store.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		ID:      params.ID,
+		Source:  webhookSourcePolka,
+		Event:   params.Event,
+		Headers: headersJSON,
+		Body:    body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't record webhook", err)
+		return
+	}
 
-	err = cfg.db.UpgradeToChirpyRed(r.Context(), params.Data.UserID)
+	code, msg, err := cfg.processPolkaWebhook(r.Context(), &params)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		respondWithError(w, r, code, msg, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// processPolkaWebhook applies a claimed event and records the outcome. It
+// is shared by live deliveries and admin replays.
+func (cfg *apiConfig) processPolkaWebhook(ctx context.Context, params *polkaWebhook) (int, string, error) {
+	start := time.Now()
+	code, msg, err := cfg.applyPolkaEvent(ctx, params.Event, params.Data)
+
+	result := database.FinishWebhookEventParams{
+		ID:           params.ID,
+		Status:       webhookProcessed,
+		ResponseCode: sql.NullInt32{Int32: int32(code), Valid: true},
+		ProcessingMs: sql.NullInt32{Int32: int32(time.Since(start).Milliseconds()), Valid: true},
+	}
+	if err != nil {
+		result.Status = webhookFailed
+		result.Error = sql.NullString{String: msg + ": " + err.Error(), Valid: true}
+	}
+
+	cfg.metrics.webhookEvents.WithLabelValues(webhookSourcePolka, params.Event, result.Status).Inc()
+
+	// The event has been applied at this point, so failing to record that
+	// shouldn't make Polka deliver it again
+	finishErr := cfg.store.FinishWebhookEvent(ctx, result)
+	if finishErr != nil {
+		slog.ErrorContext(ctx, "Couldn't record webhook result", "event_id", params.ID, "error", finishErr)
+	}
+
+	return code, msg, err
+}
+
+// applyPolkaEvent updates the user's subscription and records the change in
+// its history. On failure it returns the status and message to respond with.
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, event string, data polkaEventData) (int, string, error) {
+	_, err := cfg.store.GetUserByID(ctx, data.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, "Couldn't find user", err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Couldn't find user", err
+	}
+
+	var current *database.Subscription
+	dbSubscription, err := cfg.store.GetSubscription(ctx, data.UserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return http.StatusInternalServerError, "Couldn't get subscription", err
+	}
+	if err == nil {
+		current = &dbSubscription
+	}
+
+	next, ok := nextSubscription(event, current, data, time.Now().UTC())
+	if !ok {
+		return http.StatusNoContent, "", nil
+	}
+
+	_, err = cfg.store.SetSubscription(ctx, next)
+	if err != nil {
+		return http.StatusInternalServerError, "Couldn't update subscription", err
+	}
+
+	return http.StatusNoContent, "", nil
+}
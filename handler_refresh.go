@@ -3,7 +3,6 @@ package main
 import (
 	"chirpy/internal/auth"
 	"net/http"
-	"time"
 )
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
@@ -13,20 +12,19 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find refresh token", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find refresh token", err)
 		return
 	}
 
-	dbUser, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
+	dbUser, err := cfg.store.GetUserFromRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find user from refresh token", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find user from refresh token", err)
 		return
 	}
 
-	expiresIn := time.Hour
-	accessToken, err := auth.MakeJWT(dbUser.ID, cfg.jwtSecret, expiresIn)
+	accessToken, err := auth.MakeJWT(dbUser.ID, cfg.jwtSecret, cfg.accessTokenLifetime)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate token", err)
 		return
 	}
 
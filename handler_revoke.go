@@ -8,13 +8,13 @@ import (
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find refresh token", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find refresh token", err)
 		return
 	}
 
-	_, err = cfg.db.RevokeRefreshToken(r.Context(), refreshToken)
+	_, err = cfg.store.RevokeRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't revoke session", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't revoke session", err)
 		return
 	}
 
@@ -3,29 +3,63 @@ package main
 import (
 	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	IsChirpyRed         bool       `json:"is_chirpy_red"`
+	Badge               string     `json:"badge,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
 }
 
-func fromDbUser(u *database.User) *User {
-	return &User{
-		ID:          u.ID,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Email:       u.Email,
-		IsChirpyRed: u.IsChirpyRed,
+func fromDbUser(u *database.User, plan string, e Entitlements) *User {
+	user := &User{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerifiedAt.Valid,
+		IsChirpyRed:   plan != planFree,
+		Badge:         e.Badge,
 	}
+	if u.DeletionRequestedAt.Valid {
+		scheduledAt := u.DeletionRequestedAt.Time.Add(accountDeletionGracePeriod)
+		user.DeletionScheduledAt = &scheduledAt
+	}
+	return user
+}
+
+// userFromDb builds the API view of a user. Red status and the badge come
+// from their subscription, so it has to be looked up.
+func (cfg *apiConfig) userFromDb(ctx context.Context, u *database.User) (*User, error) {
+	plan, e, err := cfg.entitlementsFor(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return fromDbUser(u, plan, e), nil
+}
+
+func (cfg *apiConfig) respondWithUser(w http.ResponseWriter, r *http.Request, code int, u *database.User) {
+	user, err := cfg.userFromDb(r.Context(), u)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get subscription", err)
+		return
+	}
+	respondWithJSON(w, code, user)
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
@@ -38,24 +72,55 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	params := parameters{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	email, err := mailer.NormalizeAddress(params.Email)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid email address", err)
+		return
+	}
+
+	if !cfg.checkPassword(w, r, params.Password) {
 		return
 	}
 
 	hash, err := auth.HashPassword(params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
 	}
 
-	dbUser, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
-		Email:          params.Email,
+	dbUser, err := cfg.store.CreateUser(r.Context(), database.CreateUserParams{
+		Email:          email,
 		HashedPassword: hash,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
+		if isUniqueViolation(err) {
+			respondWithError(w, r, http.StatusConflict, "Email is already registered", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, fromDbUser(&dbUser))
+	// The account is usable without a verified email, and the user can ask
+	// for another link, so a mail failure shouldn't fail the signup
+	err = cfg.sendEmailVerification(r.Context(), &dbUser)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't send verification email", "error", err)
+	}
+
+	cfg.respondWithUser(w, r, http.StatusCreated, &dbUser)
+}
+
+// isUniqueViolation reports whether err is the store rejecting a duplicate
+// value for a unique column.
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, database.ErrUniqueViolation) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
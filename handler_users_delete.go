@@ -0,0 +1,199 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/mailer"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Deleted accounts can be restored for this long before they are purged
+	accountDeletionGracePeriod = 30 * 24 * time.Hour
+	accountPurgeInterval       = time.Hour
+)
+
+// handlerDeleteUser schedules the account for deletion after the grace
+// period. It needs the password as confirmation, and a JWT rather than an
+// API key, since it is the one request that can destroy everything.
+func (cfg *apiConfig) handlerDeleteUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	dbUser, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	valid, err := auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check password hash", err)
+		return
+	}
+	if !valid {
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect password", nil)
+		return
+	}
+
+	dbUser, err = cfg.db.RequestUserDeletion(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusConflict, "Account is already scheduled for deletion", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't schedule account deletion", err)
+		return
+	}
+
+	user, err := cfg.userFromDb(r.Context(), &dbUser)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get subscription", err)
+		return
+	}
+
+	// Warn the owner in case this wasn't them
+	err = cfg.mailer.Send(r.Context(), mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Your Chirpy account is scheduled for deletion",
+		Body: fmt.Sprintf(
+			"Your Chirpy account and everything in it will be permanently deleted on %s.\n\n"+
+				"If you change your mind, log in and cancel the deletion before then.\n",
+			user.DeletionScheduledAt.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't send deletion email", "error", err)
+	}
+
+	respondWithJSON(w, http.StatusAccepted, user)
+}
+
+func (cfg *apiConfig) handlerCancelUserDeletion(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	dbUser, err := cfg.db.CancelUserDeletion(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusConflict, "Account isn't scheduled for deletion", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't cancel account deletion", err)
+		return
+	}
+
+	cfg.respondWithUser(w, r, http.StatusOK, &dbUser)
+}
+
+// runAccountPurge purges accounts whose grace period has passed, until ctx
+// is cancelled.
+func (cfg *apiConfig) runAccountPurge(ctx context.Context) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		err := cfg.purgeDeletedAccounts(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Couldn't purge deleted accounts", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) error {
+	cutoff := time.Now().UTC().Add(-accountDeletionGracePeriod)
+	userIDs, err := cfg.db.ListUsersPendingDeletion(ctx, sql.NullTime{Time: cutoff, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		err = cfg.purgeUser(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("purging user %s: %w", userID, err)
+		}
+	}
+	return nil
+}
+
+// purgeUser explicitly removes everything that references the user instead
+// of relying on ON DELETE CASCADE, so adding a table without a cascade can't
+// silently leave personal data behind. Every step is idempotent and the user
+// row goes last, so a purge that fails part way is finished on the next run.
+// Lockout audit rows are kept but no longer identify anyone.
+func (cfg *apiConfig) purgeUser(ctx context.Context, userID uuid.UUID) error {
+	steps := []func(context.Context, uuid.UUID) error{
+		cfg.db.DeleteChirpsForUser,
+		cfg.db.DeleteRefreshTokensForUser,
+		cfg.db.DeleteOAuthAuthorizationCodesForUser,
+		cfg.db.DeleteOAuthClientsForUser,
+		cfg.db.DeleteAPIKeysForUser,
+		cfg.db.DeleteWebhookDeliveriesForUser,
+		cfg.db.DeleteWebhookEndpointsForUser,
+		cfg.db.DeleteWebhookEventsForUser,
+		cfg.db.DeleteSubscriptionEventsForUser,
+		cfg.db.DeleteSubscriptionForUser,
+		cfg.db.DeleteMFARecoveryCodes,
+		cfg.db.DeleteUserMFA,
+		cfg.db.DeletePasswordResetTokensForUser,
+		cfg.db.DeleteEmailVerificationTokensForUser,
+		cfg.db.ClearFailedLogins,
+	}
+	for _, step := range steps {
+		err := step(ctx, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := cfg.db.AnonymizeAccountLockoutsForUser(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return err
+	}
+
+	return cfg.db.DeleteUser(ctx, userID)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"chirpy/internal/auth"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const exportReadme = `This archive contains everything Chirpy stores about your account.
+
+profile.json        your account details
+chirps.json         every chirp you have posted
+sessions.json       login sessions, without the tokens themselves
+api_keys.json       personal API keys, without the keys themselves
+oauth_clients.json  OAuth applications you have registered
+webhooks.json       webhook endpoints you have registered, without their secrets
+two_factor.json     whether two-factor authentication is enabled
+subscription.json   your Chirpy Red subscription and its history
+
+Chirps are text only, so there is no media to include. Chirpy doesn't have
+likes or follows.
+`
+
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	ClientID  *string    `json:"client_id"`
+}
+
+type exportSubscription struct {
+	Plan               string                    `json:"plan"`
+	Status             string                    `json:"status"`
+	CurrentPeriodStart time.Time                 `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time                 `json:"current_period_end"`
+	History            []exportSubscriptionEvent `json:"history"`
+}
+
+type exportSubscriptionEvent struct {
+	CreatedAt          time.Time `json:"created_at"`
+	Event              string    `json:"event"`
+	Plan               string    `json:"plan"`
+	Status             string    `json:"status"`
+	CurrentPeriodStart time.Time `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time `json:"current_period_end"`
+}
+
+type exportTwoFactor struct {
+	Enabled   bool       `json:"enabled"`
+	EnabledAt *time.Time `json:"enabled_at"`
+}
+
+// handlerExportUser returns a zip archive of all the user's data. It is
+// built in memory so a failure part way still gets a proper error response.
+func (cfg *apiConfig) handlerExportUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	files, err := cfg.collectUserExport(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't collect account data", err)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't create archive", err)
+			return
+		}
+		_, err = fw.Write(f.data)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't create archive", err)
+			return
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create archive", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(
+		`attachment; filename="chirpy-export-%s.zip"`,
+		time.Now().UTC().Format("2006-01-02"),
+	))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+type exportFile struct {
+	name string
+	data []byte
+}
+
+func (cfg *apiConfig) collectUserExport(ctx context.Context, userID uuid.UUID) ([]exportFile, error) {
+	dbUser, err := cfg.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := cfg.userFromDb(ctx, &dbUser)
+	if err != nil {
+		return nil, err
+	}
+
+	dbChirps, err := cfg.store.ListChirpsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps := make([]*Chirp, 0, len(dbChirps))
+	for i := range dbChirps {
+		chirps = append(chirps, fromDbChirp(&dbChirps[i]))
+	}
+
+	dbTokens, err := cfg.store.ListRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]exportSession, 0, len(dbTokens))
+	for _, t := range dbTokens {
+		session := exportSession{
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+			RevokedAt: nullTimePtr(t.RevokedAt),
+		}
+		if t.ClientID.Valid {
+			session.ClientID = &t.ClientID.String
+		}
+		sessions = append(sessions, session)
+	}
+
+	dbKeys, err := cfg.db.ListAPIKeysForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*APIKey, 0, len(dbKeys))
+	for i := range dbKeys {
+		keys = append(keys, fromDbAPIKey(&dbKeys[i]))
+	}
+
+	dbClients, err := cfg.db.ListOAuthClientsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	clients := make([]*OAuthClient, 0, len(dbClients))
+	for i := range dbClients {
+		clients = append(clients, fromDbOAuthClient(&dbClients[i]))
+	}
+
+	dbEndpoints, err := cfg.db.ListWebhookEndpointsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]*WebhookEndpoint, 0, len(dbEndpoints))
+	for i := range dbEndpoints {
+		endpoints = append(endpoints, fromDbWebhookEndpoint(&dbEndpoints[i]))
+	}
+
+	twoFactor := exportTwoFactor{}
+	dbMFA, err := cfg.db.GetUserMFA(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil && dbMFA.EnabledAt.Valid {
+		twoFactor.Enabled = true
+		twoFactor.EnabledAt = &dbMFA.EnabledAt.Time
+	}
+
+	var subscription *exportSubscription
+	dbSubscription, err := cfg.store.GetSubscription(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		dbEvents, err := cfg.db.ListSubscriptionEventsForUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		subscription = &exportSubscription{
+			Plan:               dbSubscription.Plan,
+			Status:             dbSubscription.Status,
+			CurrentPeriodStart: dbSubscription.CurrentPeriodStart,
+			CurrentPeriodEnd:   dbSubscription.CurrentPeriodEnd,
+			History:            make([]exportSubscriptionEvent, 0, len(dbEvents)),
+		}
+		for _, e := range dbEvents {
+			subscription.History = append(subscription.History, exportSubscriptionEvent{
+				CreatedAt:          e.CreatedAt,
+				Event:              e.Event,
+				Plan:               e.Plan,
+				Status:             e.Status,
+				CurrentPeriodStart: e.CurrentPeriodStart,
+				CurrentPeriodEnd:   e.CurrentPeriodEnd,
+			})
+		}
+	}
+
+	files := []exportFile{{name: "README.txt", data: []byte(exportReadme)}}
+	for _, f := range []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", user},
+		{"chirps.json", chirps},
+		{"sessions.json", sessions},
+		{"api_keys.json", keys},
+		{"oauth_clients.json", clients},
+		{"webhooks.json", endpoints},
+		{"two_factor.json", twoFactor},
+		{"subscription.json", subscription},
+	} {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, exportFile{name: f.name, data: data})
+	}
+
+	return files, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// handlerPatchUser updates only the fields that are sent. Changing the email
+// or password needs the current password, so a stolen access token can't be
+// used to take over the account.
+func (cfg *apiConfig) handlerPatchUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	userID, ok := cfg.requireUser(w, r, scopeUsersWrite)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	cfg.updateUserCredentials(w, r, userID, params.Email, params.Password, params.CurrentPassword)
+}
+
+// updateUserCredentials changes the email, the password or both, with nil
+// leaving a field as it is. The current password is checked like a login
+// attempt, so guesses count towards the IP throttle and account lockout.
+// Changing the password signs out every other session.
+func (cfg *apiConfig) updateUserCredentials(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newEmail, newPassword *string, currentPassword string) {
+	oldUser, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	email := oldUser.Email
+	if newEmail != nil {
+		email, err = mailer.NormalizeAddress(*newEmail)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid email address", err)
+			return
+		}
+	}
+	emailChanged := email != oldUser.Email
+	// The current password has been checked by the time this matters, so
+	// sending it again as the new one changes nothing
+	passwordChanged := newPassword != nil && *newPassword != currentPassword
+
+	if !emailChanged && !passwordChanged {
+		cfg.respondWithUser(w, r, http.StatusOK, &oldUser)
+		return
+	}
+
+	oldUser, err = cfg.verifyLogin(r, oldUser.Email, currentPassword)
+	if err != nil {
+		if errors.Is(err, errIncorrectLogin) {
+			respondWithError(w, r, http.StatusUnauthorized, "Incorrect current password", err)
+			return
+		}
+		respondWithLoginError(w, r, err)
+		return
+	}
+
+	hash := oldUser.HashedPassword
+	if passwordChanged {
+		if !cfg.checkPassword(w, r, *newPassword) {
+			return
+		}
+		hash, err = auth.HashPassword(*newPassword)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't hash password", err)
+			return
+		}
+	}
+
+	dbUser, err := cfg.store.UpdateUser(r.Context(), database.UpdateUserParams{
+		ID:             userID,
+		Email:          email,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, r, http.StatusConflict, "Email is already registered", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
+	if emailChanged {
+		err = cfg.sendEmailVerification(r.Context(), &dbUser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Couldn't send verification email", "error", err)
+		}
+	}
+
+	if !passwordChanged {
+		cfg.respondWithUser(w, r, http.StatusOK, &dbUser)
+		return
+	}
+
+	// Refresh tokens don't say which one belongs to this caller, so revoke
+	// them all and hand back a fresh session in place of the caller's
+	err = cfg.store.RevokeAllRefreshTokensForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	cfg.respondWithSession(w, r, &dbUser)
+}
@@ -1,53 +1,32 @@
 package main
 
 import (
-	"chirpy/internal/auth"
-	"chirpy/internal/database"
 	"encoding/json"
 	"net/http"
 )
 
+// handlerUpdateUser replaces the email and password. It's confirmed with the
+// current password and signs out other sessions the same way as
+// handlerPatchUser.
 func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		CurrentPassword string `json:"current_password"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+	userID, ok := cfg.requireUser(w, r, scopeUsersWrite)
+	if !ok {
 		return
 	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-		return
-	}
-
-	hash, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
-		return
-	}
-
-	dbUser, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hash,
-	})
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, fromDbUser(&dbUser))
+	cfg.updateUserCredentials(w, r, userID, &params.Email, &params.Password, params.CurrentPassword)
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+}
+
+func fromDbWebhookEndpoint(e *database.WebhookEndpoint) *WebhookEndpoint {
+	return &WebhookEndpoint{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		URL:       e.Url,
+		Events:    e.Events,
+	}
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt *time.Time      `json:"last_attempt_at"`
+	ResponseCode  *int32          `json:"response_code"`
+	Error         *string         `json:"error"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+}
+
+func fromDbWebhookDelivery(d *database.WebhookDelivery) *WebhookDelivery {
+	delivery := &WebhookDelivery{
+		ID:            d.ID,
+		CreatedAt:     d.CreatedAt,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		LastAttemptAt: nullTimePtr(d.LastAttemptAt),
+		DeliveredAt:   nullTimePtr(d.DeliveredAt),
+	}
+	if d.Status == webhookDeliveryPending {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.ResponseCode.Valid {
+		delivery.ResponseCode = &d.ResponseCode.Int32
+	}
+	if d.Error.Valid {
+		delivery.Error = &d.Error.String
+	}
+	return delivery
+}
+
+func (cfg *apiConfig) handlerCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	type response struct {
+		WebhookEndpoint
+		Secret string `json:"secret"`
+	}
+
+	userID, ok := cfg.requireUser(w, r, scopeWebhooksWrite)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Webhook URL must be an https URL", err)
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "At least one event is required", nil)
+		return
+	}
+	for _, event := range params.Events {
+		if !slices.Contains(validWebhookEvents, event) {
+			respondWithError(w, r, http.StatusBadRequest, "Unknown event: "+event, nil)
+			return
+		}
+	}
+
+	existing, err := cfg.db.ListWebhookEndpointsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error fetching webhook endpoints", err)
+		return
+	}
+	if len(existing) >= webhookMaxEndpoints {
+		respondWithError(w, r, http.StatusConflict, "Too many webhook endpoints", nil)
+		return
+	}
+
+	// The secret is kept in plain text since it is needed to sign deliveries
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create webhook secret", err)
+		return
+	}
+	secret = "whsec_" + secret
+
+	dbEndpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    u.String(),
+		Events: params.Events,
+		Secret: secret,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save webhook endpoint", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		WebhookEndpoint: *fromDbWebhookEndpoint(&dbEndpoint),
+		Secret:          secret,
+	})
+}
+
+func (cfg *apiConfig) handlerListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.requireUser(w, r, scopeWebhooksWrite)
+	if !ok {
+		return
+	}
+
+	dbEndpoints, err := cfg.db.ListWebhookEndpointsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error fetching webhook endpoints", err)
+		return
+	}
+
+	endpoints := []*WebhookEndpoint{}
+	for i := range dbEndpoints {
+		endpoints = append(endpoints, fromDbWebhookEndpoint(&dbEndpoints[i]))
+	}
+
+	respondWithJSON(w, http.StatusOK, endpoints)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid webhook endpoint ID", err)
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r, scopeWebhooksWrite)
+	if !ok {
+		return
+	}
+
+	_, err = cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Couldn't find webhook endpoint", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete webhook endpoint", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListWebhookDeliveries is the delivery log for one endpoint, newest
+// first, including dead-lettered deliveries.
+func (cfg *apiConfig) handlerListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	const (
+		defaultLimit = 50
+		maxLimit     = 500
+	)
+
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxLimit {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = n
+	}
+
+	dbDeliveries, err := cfg.db.ListWebhookDeliveriesForEndpoint(r.Context(), database.ListWebhookDeliveriesForEndpointParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error fetching webhook deliveries", err)
+		return
+	}
+
+	deliveries := []*WebhookDelivery{}
+	for i := range dbDeliveries {
+		deliveries = append(deliveries, fromDbWebhookDelivery(&dbDeliveries[i]))
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// handlerRetryWebhookDelivery queues a dead-lettered delivery again, with a
+// fresh set of attempts.
+func (cfg *apiConfig) handlerRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid webhook delivery ID", err)
+		return
+	}
+
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	dbDelivery, err := cfg.db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Couldn't find a dead-lettered delivery with that ID", err)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't retry webhook delivery", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, fromDbWebhookDelivery(&dbDelivery))
+}
+
+// ownedWebhookEndpoint authenticates the request and loads the endpoint in
+// the path. Other users' endpoints are reported as not found.
+func (cfg *apiConfig) ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid webhook endpoint ID", err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	userID, ok := cfg.requireUser(w, r, scopeWebhooksWrite)
+	if !ok {
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpointForUser(r.Context(), database.GetWebhookEndpointForUserParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Couldn't find webhook endpoint", err)
+			return database.WebhookEndpoint{}, false
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get webhook endpoint", err)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
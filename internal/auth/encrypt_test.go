@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+)
+
+// TestEncryptSecret round-trips a secret through encryption
+func TestEncryptSecret(t *testing.T) {
+	secret := "JBSWY3DPEHPK3PXP"
+	ciphertext, err := EncryptSecret(secret, "encryption-key")
+	if err != nil {
+		t.Fatalf("EncryptSecret() returned an error: %v", err)
+	}
+	if ciphertext == secret {
+		t.Error("EncryptSecret() returned the secret unencrypted")
+	}
+
+	plaintext, err := DecryptSecret(ciphertext, "encryption-key")
+	if err != nil {
+		t.Fatalf("DecryptSecret() returned an error: %v", err)
+	}
+	if plaintext != secret {
+		t.Errorf("DecryptSecret() = %q, expected %q", plaintext, secret)
+	}
+}
+
+// TestDecryptSecretWrongKey fails to decrypt with a different key
+func TestDecryptSecretWrongKey(t *testing.T) {
+	ciphertext, err := EncryptSecret("JBSWY3DPEHPK3PXP", "encryption-key")
+	if err != nil {
+		t.Fatalf("EncryptSecret() failed: %v", err)
+	}
+
+	_, err = DecryptSecret(ciphertext, "other-key")
+	if err == nil {
+		t.Fatal("expected decryption to fail with the wrong key")
+	}
+}
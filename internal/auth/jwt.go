@@ -13,24 +13,48 @@ type TokenType string
 const (
 	// TokenTypeAccess -
 	TokenTypeAccess TokenType = "chirpy-access"
+	// TokenTypeMFAChallenge -
+	TokenTypeMFAChallenge TokenType = "chirpy-mfa-challenge"
 )
 
 // MakeJWT -
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return makeJWT(TokenTypeAccess, userID, tokenSecret, expiresIn)
+}
+
+// ValidateJWT -
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return validateJWT(TokenTypeAccess, tokenString, tokenSecret)
+}
+
+// MakeMFAChallengeJWT issues the short-lived token a client trades, together
+// with a second factor, for a real access token.
+func MakeMFAChallengeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return makeJWT(TokenTypeMFAChallenge, userID, tokenSecret, expiresIn)
+}
+
+// ValidateMFAChallengeJWT -
+func ValidateMFAChallengeJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return validateJWT(TokenTypeMFAChallenge, tokenString, tokenSecret)
+}
+
+func makeJWT(tokenType TokenType, userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	signingKey := []byte(tokenSecret)
 	claims := &jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
+		Issuer:    string(tokenType),
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 		Subject:   userID.String(),
+		// Tokens issued in the same second are otherwise identical, and MFA
+		// challenges are tracked by their hash
+		ID: uuid.NewString(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(signingKey)
 }
 
-// ValidateJWT -
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+func validateJWT(tokenType TokenType, tokenString, tokenSecret string) (uuid.UUID, error) {
 	claimsStruct := jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
@@ -38,6 +62,8 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 		func(token *jwt.Token) (any, error) {
 			return []byte(tokenSecret), nil
 		},
+		// Tokens of one type must never be accepted as another
+		jwt.WithIssuer(string(tokenType)),
 	)
 	if err != nil {
 		return uuid.Nil, err
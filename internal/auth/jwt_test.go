@@ -72,3 +72,38 @@ func TestWrongSecret(t *testing.T) {
 		t.Fatalf("expected ErrSignatureInvalid, got %v", err)
 	}
 }
+
+// TestMFAChallengeJWTNotAccessToken keeps challenge tokens and access tokens
+// from being used in place of each other
+func TestMFAChallengeJWTNotAccessToken(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "AllYourBase"
+
+	challenge, err := MakeMFAChallengeJWT(userID, tokenSecret, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMFAChallengeJWT() failed: %v", err)
+	}
+
+	validatedID, err := ValidateMFAChallengeJWT(challenge, tokenSecret)
+	if err != nil {
+		t.Fatalf("ValidateMFAChallengeJWT() returned an error: %v", err)
+	}
+	if validatedID != userID {
+		t.Error("ValidateMFAChallengeJWT() failed to validate userID")
+	}
+
+	_, err = ValidateJWT(challenge, tokenSecret)
+	if !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Fatalf("expected ErrTokenInvalidIssuer for challenge token, got %v", err)
+	}
+
+	access, err := MakeJWT(userID, tokenSecret, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() failed: %v", err)
+	}
+
+	_, err = ValidateMFAChallengeJWT(access, tokenSecret)
+	if !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Fatalf("expected ErrTokenInvalidIssuer for access token, got %v", err)
+	}
+}
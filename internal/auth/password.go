@@ -4,8 +4,16 @@ import (
 	"github.com/alexedwards/argon2id"
 )
 
+var passwordParams = argon2id.DefaultParams
+
+// SetPasswordParams - sets the argon2id parameters used for new hashes. It
+// isn't safe to call while passwords are being hashed, so call it at startup.
+func SetPasswordParams(params *argon2id.Params) {
+	passwordParams = params
+}
+
 func HashPassword(password string) (string, error) {
-	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	hash, err := argon2id.CreateHash(password, passwordParams)
 	if err != nil {
 		return "", err
 	}
@@ -21,3 +29,18 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 
 	return match, nil
 }
+
+// PasswordNeedsRehash - reports whether hash was created with weaker
+// parameters than the current ones. Parallelism is ignored since it depends
+// on the machine and lowering it doesn't make a hash cheaper to crack.
+func PasswordNeedsRehash(hash string) (bool, error) {
+	params, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	return params.Memory < passwordParams.Memory ||
+		params.Iterations < passwordParams.Iterations ||
+		params.SaltLength < passwordParams.SaltLength ||
+		params.KeyLength < passwordParams.KeyLength, nil
+}
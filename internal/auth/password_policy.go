@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	PasswordTooShort = "too_short"
+	PasswordTooLong  = "too_long"
+	PasswordBreached = "breached"
+)
+
+// breachedPrefixLength matches the range size used by the Pwned Passwords
+// k-anonymity API, so its downloaded dumps can be used as-is.
+const breachedPrefixLength = 5
+
+// PasswordPolicy - rules a new password must satisfy. MaxLength is in bytes
+// since that is what bounds the cost of hashing.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	Breached  *BreachedPasswords
+}
+
+// PasswordViolation - one rule a password failed.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError - every rule a password failed, not just the first.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	codes := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		codes = append(codes, v.Code)
+	}
+	return "password doesn't meet policy: " + strings.Join(codes, ", ")
+}
+
+// Validate - returns a *PasswordPolicyError if the password breaks any rule.
+func (p *PasswordPolicy) Validate(password string) error {
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("Password must be at most %d bytes", p.MaxLength),
+		})
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordBreached,
+			Message: "Password has appeared in a data breach, please choose another",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// BreachedPasswords - SHA-1 hashes of known breached passwords, grouped by
+// hash prefix the same way as the Pwned Passwords range API.
+type BreachedPasswords struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedPasswords - reads a file of uppercase or lowercase SHA-1 hashes,
+// one per line, optionally followed by ":count" as in the Pwned Passwords
+// downloads. Blank lines and lines starting with # are ignored.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := &BreachedPasswords{ranges: map[string][]string{}}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, lineNo)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash", path, lineNo)
+		}
+
+		prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+		b.ranges[prefix] = append(b.ranges[prefix], suffix)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for prefix := range b.ranges {
+		slices.Sort(b.ranges[prefix])
+	}
+
+	return b, nil
+}
+
+// Contains - reports whether the password's hash is in the list.
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.ranges[hash[:breachedPrefixLength]]
+	_, found := slices.BinarySearch(suffixes, hash[breachedPrefixLength:])
+	return found
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeBreachedFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte(contents), 0o600)
+	if err != nil {
+		t.Fatalf("Couldn't write breached password file: %v", err)
+	}
+	return path
+}
+
+// TestLoadBreachedPasswords checks both bare hashes and the hash:count
+// format, in either case.
+func TestLoadBreachedPasswords(t *testing.T) {
+	path := writeBreachedFile(t, `# sha1 of "password" and "password123"
+5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+
+cbfdac6008f9cab4083784cbd1874f76618d2a97
+`)
+
+	breached, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() returned an error: %v", err)
+	}
+
+	for _, password := range []string{"password", "password123"} {
+		if !breached.Contains(password) {
+			t.Errorf("Contains(%q) = false, expected true", password)
+		}
+	}
+	if breached.Contains("correct horse battery staple") {
+		t.Error("Contains() matched a password that isn't in the list")
+	}
+}
+
+// TestLoadBreachedPasswordsInvalid rejects lines that aren't SHA-1 hashes.
+func TestLoadBreachedPasswordsInvalid(t *testing.T) {
+	path := writeBreachedFile(t, "not-a-hash\n")
+
+	_, err := LoadBreachedPasswords(path)
+	if err == nil {
+		t.Error("LoadBreachedPasswords() should return an error for an invalid line")
+	}
+}
+
+// TestPasswordPolicyValidate reports every violated rule.
+func TestPasswordPolicyValidate(t *testing.T) {
+	breached, err := LoadBreachedPasswords(writeBreachedFile(t, "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n"))
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() returned an error: %v", err)
+	}
+	policy := PasswordPolicy{MinLength: 10, MaxLength: 20, Breached: breached}
+
+	tests := []struct {
+		name     string
+		password string
+		expected []string
+	}{
+		{name: "valid", password: "heisenberg-blue", expected: nil},
+		{name: "empty", password: "", expected: []string{PasswordTooShort}},
+		{name: "too long", password: "say-my-name-say-my-name", expected: []string{PasswordTooLong}},
+		{name: "short and breached", password: "password", expected: []string{PasswordTooShort, PasswordBreached}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatalf("Validate() returned an error: %v", err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %v, expected a *PasswordPolicyError", err)
+			}
+			if len(policyErr.Violations) != len(tt.expected) {
+				t.Fatalf("Validate() returned %d violations, expected %d", len(policyErr.Violations), len(tt.expected))
+			}
+			for i, code := range tt.expected {
+				if policyErr.Violations[i].Code != code {
+					t.Errorf("violation %d = %q, expected %q", i, policyErr.Violations[i].Code, code)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"testing"
+
+	"github.com/alexedwards/argon2id"
 )
 
 // TestHashPassword calls auth.HashPassword with a password, returning a hashed
@@ -91,3 +93,48 @@ func TestCheckPasswordHashInvalidHash(t *testing.T) {
 		t.Error("CheckPasswordHash() should not match with invalid hash")
 	}
 }
+
+// TestPasswordNeedsRehash flags hashes made before the parameters were
+// raised, but not hashes made with the current ones.
+func TestPasswordNeedsRehash(t *testing.T) {
+	defer SetPasswordParams(passwordParams)
+
+	weak := &argon2id.Params{Memory: 16 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	strong := &argon2id.Params{Memory: 32 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	SetPasswordParams(weak)
+	oldHash, err := HashPassword("my_password")
+	if err != nil {
+		t.Fatalf("HashPassword() failed: %v", err)
+	}
+
+	SetPasswordParams(strong)
+	needsRehash, err := PasswordNeedsRehash(oldHash)
+	if err != nil {
+		t.Fatalf("PasswordNeedsRehash() returned an error: %v", err)
+	}
+	if !needsRehash {
+		t.Error("PasswordNeedsRehash() = false for a hash with weaker parameters")
+	}
+
+	newHash, err := HashPassword("my_password")
+	if err != nil {
+		t.Fatalf("HashPassword() failed: %v", err)
+	}
+	needsRehash, err = PasswordNeedsRehash(newHash)
+	if err != nil {
+		t.Fatalf("PasswordNeedsRehash() returned an error: %v", err)
+	}
+	if needsRehash {
+		t.Error("PasswordNeedsRehash() = true for a hash with the current parameters")
+	}
+
+	// Old hashes still verify after the parameters change
+	match, err := CheckPasswordHash("my_password", oldHash)
+	if err != nil {
+		t.Fatalf("CheckPasswordHash() returned an error: %v", err)
+	}
+	if !match {
+		t.Error("CheckPasswordHash() failed to match a hash made with old parameters")
+	}
+}
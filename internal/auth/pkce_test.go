@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestVerifyCodeChallenge accepts the verifier the challenge was made from
+func TestVerifyCodeChallenge(t *testing.T) {
+	// Example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := MakeCodeChallenge(verifier); got != challenge {
+		t.Fatalf("MakeCodeChallenge() = %q, expected %q", got, challenge)
+	}
+	if err := VerifyCodeChallenge(verifier, challenge); err != nil {
+		t.Fatalf("VerifyCodeChallenge() returned an error: %v", err)
+	}
+}
+
+// TestVerifyCodeChallengeMismatch rejects the wrong verifier
+func TestVerifyCodeChallengeMismatch(t *testing.T) {
+	challenge := MakeCodeChallenge(strings.Repeat("a", 43))
+
+	err := VerifyCodeChallenge(strings.Repeat("b", 43), challenge)
+	if !errors.Is(err, ErrInvalidCodeVerifier) {
+		t.Fatalf("expected ErrInvalidCodeVerifier, got %v", err)
+	}
+}
+
+// TestVerifyCodeChallengeLength rejects verifiers outside the RFC bounds
+func TestVerifyCodeChallengeLength(t *testing.T) {
+	for _, verifier := range []string{"short", strings.Repeat("a", 129)} {
+		err := VerifyCodeChallenge(verifier, MakeCodeChallenge(verifier))
+		if !errors.Is(err, ErrInvalidCodeVerifier) {
+			t.Errorf("expected ErrInvalidCodeVerifier for length %d, got %v", len(verifier), err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -51,3 +52,41 @@ func MakeRefreshToken() (string, error) {
 
 	return hex.EncodeToString(key), nil
 }
+
+// APIKeyPrefix marks personal API keys so they are easy to spot in logs and
+// secret scanners.
+const APIKeyPrefix = "chirpy"
+
+// MakeAPIKey returns a new personal API key together with its lookup prefix.
+// Only the prefix may be stored in plain text; the full key must be hashed.
+func MakeAPIKey() (prefix, key string, err error) {
+	prefixBytes := make([]byte, 8)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = APIKeyPrefix + "_" + prefix + "_" + hex.EncodeToString(secretBytes)
+	return prefix, key, nil
+}
+
+// ParseAPIKey returns the lookup prefix of a key created by MakeAPIKey.
+func ParseAPIKey(key string) (string, error) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", errors.New("malformed API key")
+	}
+
+	return parts[1], nil
+}
+
+// HashToken returns a SHA-256 digest of a high-entropy random token so it can
+// be stored and looked up without keeping the token itself at rest.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
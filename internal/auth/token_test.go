@@ -39,3 +39,31 @@ func TestInvalidAuhtorizationHeader(t *testing.T) {
 		t.Fatal("expected validation to fail for malformed token")
 	}
 }
+
+// TestMakeAPIKey creates a key whose prefix can be parsed back out
+func TestMakeAPIKey(t *testing.T) {
+	prefix, key, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("MakeAPIKey() returned an error: %v", err)
+	}
+	if prefix == "" || key == "" {
+		t.Fatal("MakeAPIKey() returned an empty prefix or key")
+	}
+
+	parsed, err := ParseAPIKey(key)
+	if err != nil {
+		t.Fatalf("ParseAPIKey() returned an error: %v", err)
+	}
+	if parsed != prefix {
+		t.Errorf("ParseAPIKey() = %q, expected %q", parsed, prefix)
+	}
+}
+
+// TestParseAPIKeyMalformed rejects keys that weren't made by MakeAPIKey
+func TestParseAPIKeyMalformed(t *testing.T) {
+	for _, key := range []string{"", "abc123", "chirpy_abc", "other_abc_def", "chirpy__def"} {
+		if _, err := ParseAPIKey(key); err == nil {
+			t.Errorf("ParseAPIKey(%q) should have failed", key)
+		}
+	}
+}
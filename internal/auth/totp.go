@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// totpPeriod is the default TOTP step used by authenticator apps
+const totpPeriod = 30
+
+// ValidateTOTP checks a TOTP code against the secret, allowing one step of
+// clock drift either way as authenticator apps expect. It returns the time
+// step the code belongs to, so the caller can refuse to accept that step, or
+// any earlier one, a second time.
+func ValidateTOTP(code, secret string, now time.Time) (int64, bool) {
+	step := now.Unix() / totpPeriod
+	for _, s := range []int64{step - 1, step, step + 1} {
+		expected, err := totp.GenerateCode(secret, time.Unix(s*totpPeriod, 0))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
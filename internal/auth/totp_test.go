@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// TestValidateTOTP accepts codes within one step of now and reports the step
+func TestValidateTOTP(t *testing.T) {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "Chirpy", AccountName: "walt@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_010, 0)
+	step := now.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := totp.GenerateCode(key.Secret(), now.Add(time.Duration(offset*totpPeriod)*time.Second))
+		got, ok := ValidateTOTP(code, key.Secret(), now)
+		if !ok || got != step+offset {
+			t.Errorf("ValidateTOTP() for offset %d = %d, %v, expected %d, true", offset, got, ok, step+offset)
+		}
+	}
+
+	code, _ := totp.GenerateCode(key.Secret(), now.Add(2*totpPeriod*time.Second))
+	if _, ok := ValidateTOTP(code, key.Secret(), now); ok {
+		t.Error("ValidateTOTP() accepted a code two steps ahead")
+	}
+	if _, ok := ValidateTOTP("", key.Secret(), now); ok {
+		t.Error("ValidateTOTP() accepted an empty code")
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookTimestampHeader = "X-Polka-Timestamp"
+	WebhookSignatureHeader = "X-Polka-Signature"
+
+	// webhookSignatureScheme prefixes each signature so the scheme can be
+	// changed later without ambiguity
+	webhookSignatureScheme = "v1"
+)
+
+// ErrMissingWebhookSignature -
+var ErrMissingWebhookSignature = errors.New("missing webhook signature or timestamp")
+
+// ErrInvalidWebhookSignature -
+var ErrInvalidWebhookSignature = errors.New("webhook signature doesn't match")
+
+// ErrWebhookTimestampOutOfRange -
+var ErrWebhookTimestampOutOfRange = errors.New("webhook timestamp is outside the tolerance window")
+
+// SignWebhook - returns the signature header value for body sent at
+// timestamp: "v1=" and the hex HMAC-SHA256 of "<unix timestamp>.<body>".
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	return webhookSignatureScheme + "=" + hex.EncodeToString(
+		webhookMAC(secret, strconv.FormatInt(timestamp.Unix(), 10), body),
+	)
+}
+
+// VerifyWebhookSignature - checks the timestamp is within tolerance of now
+// and that one of the signatures in the header was made with one of the
+// secrets. Accepting several of each lets keys be rotated without downtime.
+func VerifyWebhookSignature(headers http.Header, body []byte, secrets []string, now time.Time, tolerance time.Duration) error {
+	timestamp := headers.Get(WebhookTimestampHeader)
+	signatureHeader := headers.Get(WebhookSignatureHeader)
+	if timestamp == "" || signatureHeader == "" {
+		return ErrMissingWebhookSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingWebhookSignature
+	}
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > tolerance || skew < -tolerance {
+		return ErrWebhookTimestampOutOfRange
+	}
+
+	var signatures [][]byte
+	for _, part := range strings.Split(signatureHeader, ",") {
+		scheme, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || scheme != webhookSignatureScheme {
+			continue
+		}
+		sig, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		signatures = append(signatures, sig)
+	}
+
+	for _, secret := range secrets {
+		expected := webhookMAC(secret, timestamp, body)
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				return nil
+			}
+		}
+	}
+
+	return ErrInvalidWebhookSignature
+}
+
+func webhookMAC(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeaders(secret string, timestamp time.Time, body []byte) http.Header {
+	headers := http.Header{}
+	headers.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	headers.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, body))
+	return headers
+}
+
+// TestVerifyWebhookSignature covers valid, tampered and stale deliveries.
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	secrets := []string{"current-secret"}
+
+	tests := []struct {
+		name     string
+		headers  http.Header
+		body     []byte
+		expected error
+	}{
+		{
+			name:     "valid",
+			headers:  signedHeaders("current-secret", now, body),
+			body:     body,
+			expected: nil,
+		},
+		{
+			name:     "tampered body",
+			headers:  signedHeaders("current-secret", now, body),
+			body:     []byte(`{"id":"evt_1","event":"user.downgraded"}`),
+			expected: ErrInvalidWebhookSignature,
+		},
+		{
+			name:     "wrong secret",
+			headers:  signedHeaders("someone-else", now, body),
+			body:     body,
+			expected: ErrInvalidWebhookSignature,
+		},
+		{
+			name:     "too old",
+			headers:  signedHeaders("current-secret", now.Add(-10*time.Minute), body),
+			body:     body,
+			expected: ErrWebhookTimestampOutOfRange,
+		},
+		{
+			name:     "too far in the future",
+			headers:  signedHeaders("current-secret", now.Add(10*time.Minute), body),
+			body:     body,
+			expected: ErrWebhookTimestampOutOfRange,
+		},
+		{
+			name:     "missing headers",
+			headers:  http.Header{},
+			body:     body,
+			expected: ErrMissingWebhookSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.headers, tt.body, secrets, now, 5*time.Minute)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("VerifyWebhookSignature() error = %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+// TestVerifyWebhookSignatureRotation accepts either key while both are
+// active, and a header carrying signatures from both.
+func TestVerifyWebhookSignatureRotation(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"evt_2"}`)
+	secrets := []string{"new-secret", "old-secret"}
+
+	err := VerifyWebhookSignature(signedHeaders("old-secret", now, body), body, secrets, now, time.Minute)
+	if err != nil {
+		t.Errorf("VerifyWebhookSignature() rejected the old key: %v", err)
+	}
+
+	headers := signedHeaders("unknown-secret", now, body)
+	headers.Set(WebhookSignatureHeader, headers.Get(WebhookSignatureHeader)+", "+SignWebhook("new-secret", now, body))
+	err = VerifyWebhookSignature(headers, body, secrets, now, time.Minute)
+	if err != nil {
+		t.Errorf("VerifyWebhookSignature() rejected a header with one valid signature: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting read at startup. Each field can be set, from
+// lowest to highest precedence, by its default, the YAML config file, its
+// environment variable and its command-line flag. The flag name is the YAML
+// path with dashes, e.g. -server-port.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Auth      Auth      `yaml:"auth"`
+	Passwords Passwords `yaml:"passwords"`
+	Mail      Mail      `yaml:"mail"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+	// EntitlementsFile overrides the default plan entitlements
+	EntitlementsFile string `yaml:"entitlements_file" env:"ENTITLEMENTS_FILE"`
+}
+
+// Server -
+type Server struct {
+	Port       int    `yaml:"port" env:"PORT"`
+	StaticPath string `yaml:"static_path" env:"STATIC_PATH"`
+	// BaseURL is the public URL, used in links sent by email
+	BaseURL  string `yaml:"base_url" env:"BASE_URL"`
+	Platform string `yaml:"platform" env:"PLATFORM"`
+
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+	// ShutdownDelay is how long readiness fails before the listener closes
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// Database -
+type Database struct {
+	URL string `yaml:"url" env:"DB_URL" secret:"true"`
+	// MigrateOnStart applies pending migrations before serving
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+}
+
+// Auth -
+type Auth struct {
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	// Several keys can be active while one is being rotated
+	PolkaKeys        []string `yaml:"polka_keys" env:"POLKA_KEY" secret:"true"`
+	MFAEncryptionKey string   `yaml:"mfa_encryption_key" env:"MFA_ENCRYPTION_KEY" secret:"true"`
+	// AdminKey is optional; without it the admin API is disabled
+	AdminKey string `yaml:"admin_key" env:"ADMIN_KEY" secret:"true"`
+
+	AccessTokenLifetime  time.Duration `yaml:"access_token_lifetime" env:"ACCESS_TOKEN_LIFETIME"`
+	RefreshTokenLifetime time.Duration `yaml:"refresh_token_lifetime" env:"REFRESH_TOKEN_LIFETIME"`
+}
+
+// Passwords -
+type Passwords struct {
+	MinLength    int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MaxLength    int    `yaml:"max_length" env:"PASSWORD_MAX_LENGTH"`
+	BreachedFile string `yaml:"breached_file" env:"BREACHED_PASSWORDS_FILE"`
+
+	Argon2MemoryKiB   int `yaml:"argon2_memory_kib" env:"ARGON2_MEMORY_KIB"`
+	Argon2Iterations  int `yaml:"argon2_iterations" env:"ARGON2_ITERATIONS"`
+	Argon2Parallelism int `yaml:"argon2_parallelism" env:"ARGON2_PARALLELISM"`
+}
+
+// Mail -
+type Mail struct {
+	SMTPAddr     string `yaml:"smtp_addr" env:"SMTP_ADDR"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// Log -
+type Log struct {
+	Level slog.Level `yaml:"level" env:"LOG_LEVEL"`
+}
+
+// Tracing -
+type Tracing struct {
+	// Exporter is empty, stdout or otlp
+	Exporter     string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName  string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// Default returns the settings used when nothing else is configured.
+// Required settings are left empty.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:            8080,
+			StaticPath:      ".",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			ShutdownDelay:   5 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Auth: Auth{
+			AccessTokenLifetime:  time.Hour,
+			RefreshTokenLifetime: 60 * 24 * time.Hour,
+		},
+		Passwords: Passwords{
+			MinLength: 8,
+			// argon2 hashes the whole input, so an unbounded password lets a
+			// single request burn arbitrary CPU
+			MaxLength:         128,
+			Argon2MemoryKiB:   int(argon2id.DefaultParams.Memory),
+			Argon2Iterations:  int(argon2id.DefaultParams.Iterations),
+			Argon2Parallelism: int(argon2id.DefaultParams.Parallelism),
+		},
+		Log: Log{
+			Level: slog.LevelInfo,
+		},
+		Tracing: Tracing{
+			ServiceName: "chirpy",
+		},
+	}
+}
+
+// Load builds the config from the defaults, the file named by -config or
+// CONFIG_FILE, the environment and the command-line flags. Every setting is
+// registered on fs, so the caller can add flags of its own before calling
+// Load. Empty environment variables count as unset. Values that don't parse
+// are errors, but the result isn't validated, since subcommands only need
+// some of it; the server calls Validate.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	fields := cfg.fields()
+
+	configFile := fs.String("config", "", "path to a YAML config file (env CONFIG_FILE)")
+	flags := map[string]*field{}
+	for _, f := range fields {
+		// Secrets would show up in the process list
+		if f.secret {
+			continue
+		}
+		flags[f.flagName()] = f
+		fs.Var(f, f.flagName(), fmt.Sprintf("%s (env %s)", f.path, f.env))
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if v := getenv(f.env); v != "" {
+			if err := f.set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if f, ok := flags[fl.Name]; ok {
+			// Parse has already checked the value
+			f.set(f.raw)
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil {
+		return fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid or missing setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port must be between 1 and 65535")
+	check(c.Server.StaticPath != "", "server.static_path must be set")
+	u, err := url.Parse(c.Server.BaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "server.base_url must be an http or https URL")
+	check(c.Server.Platform != "", "server.platform must be set")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be positive")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay can't be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	check(c.Database.URL != "", "database.url must be set")
+
+	check(c.Auth.JWTSecret != "", "auth.jwt_secret must be set")
+	check(len(c.Auth.PolkaKeys) > 0, "auth.polka_keys must be set")
+	for _, key := range c.Auth.PolkaKeys {
+		// An empty HMAC key isn't a secret
+		check(key != "", "auth.polka_keys can't contain an empty key")
+	}
+	check(c.Auth.MFAEncryptionKey != "", "auth.mfa_encryption_key must be set")
+	check(c.Auth.AccessTokenLifetime > 0, "auth.access_token_lifetime must be positive")
+	check(c.Auth.RefreshTokenLifetime > 0, "auth.refresh_token_lifetime must be positive")
+
+	check(c.Passwords.MinLength > 0, "passwords.min_length must be positive")
+	check(c.Passwords.MaxLength >= c.Passwords.MinLength, "passwords.max_length must be at least passwords.min_length")
+	check(c.Passwords.Argon2MemoryKiB > 0 && c.Passwords.Argon2MemoryKiB <= math.MaxUint32, "passwords.argon2_memory_kib must be between 1 and %d", uint32(math.MaxUint32))
+	check(c.Passwords.Argon2Iterations > 0 && c.Passwords.Argon2Iterations <= math.MaxUint32, "passwords.argon2_iterations must be between 1 and %d", uint32(math.MaxUint32))
+	check(c.Passwords.Argon2Parallelism > 0 && c.Passwords.Argon2Parallelism < 256, "passwords.argon2_parallelism must be between 1 and 255")
+
+	check(c.Mail.SMTPAddr != "", "mail.smtp_addr must be set")
+	check(c.Mail.From != "", "mail.from must be set")
+
+	switch c.Tracing.Exporter {
+	case "", "stdout":
+	case "otlp":
+		check(c.Tracing.OTLPEndpoint != "", "tracing.otlp_endpoint must be set for the otlp exporter")
+	default:
+		check(false, "tracing.exporter must be stdout or otlp")
+	}
+	check(c.Tracing.ServiceName != "", "tracing.service_name must be set")
+
+	return errors.Join(errs...)
+}
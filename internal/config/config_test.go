@@ -0,0 +1,160 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// required has every setting without a default, so Load can succeed
+var required = map[string]string{
+	"BASE_URL":           "https://chirpy.example.com",
+	"PLATFORM":           "dev",
+	"DB_URL":             "postgres://chirpy:hunter2@db:5432/chirpy",
+	"JWT_SECRET":         "jwt-secret",
+	"POLKA_KEY":          "polka-old, polka-new,",
+	"MFA_ENCRYPTION_KEY": "mfa-key",
+	"SMTP_ADDR":          "localhost:1025",
+	"MAIL_FROM":          "chirpy@example.com",
+}
+
+func load(t *testing.T, args []string, env map[string]string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := Load(fs, args, func(key string) string {
+		if v, ok := env[key]; ok {
+			return v
+		}
+		return required[key]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil, nil)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Server.Port != 8080 || cfg.Auth.AccessTokenLifetime != time.Hour {
+		t.Errorf("Defaults not applied: port %d, access token lifetime %v", cfg.Server.Port, cfg.Auth.AccessTokenLifetime)
+	}
+	if len(cfg.Auth.PolkaKeys) != 2 || cfg.Auth.PolkaKeys[1] != "polka-new" {
+		t.Errorf("PolkaKeys = %q, expected the two listed keys", cfg.Auth.PolkaKeys)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chirpy.yaml")
+	err := os.WriteFile(path, []byte(`
+server:
+  port: 9000
+  read_timeout: 30s
+  write_timeout: 30s
+auth:
+  access_token_lifetime: 15m
+log:
+  level: debug
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load(t,
+		[]string{"-config", path, "-server-port", "9100"},
+		map[string]string{"PORT": "9050", "WRITE_TIMEOUT": "45s"},
+	)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	if cfg.Server.Port != 9100 {
+		t.Errorf("Port = %d, expected the flag to win", cfg.Server.Port)
+	}
+	if cfg.Server.WriteTimeout != 45*time.Second {
+		t.Errorf("WriteTimeout = %v, expected the environment to beat the file", cfg.Server.WriteTimeout)
+	}
+	if cfg.Server.ReadTimeout != 30*time.Second || cfg.Auth.AccessTokenLifetime != 15*time.Minute {
+		t.Errorf("File settings not applied: %v, %v", cfg.Server.ReadTimeout, cfg.Auth.AccessTokenLifetime)
+	}
+	if cfg.Log.Level.String() != "DEBUG" {
+		t.Errorf("Log level = %v, expected DEBUG", cfg.Log.Level)
+	}
+	if cfg.Server.StaticPath != "." {
+		t.Errorf("StaticPath = %q, expected the default", cfg.Server.StaticPath)
+	}
+
+	cfg, err = load(t, []string{"-database-migrate-on-start"}, nil)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !cfg.Database.MigrateOnStart {
+		t.Error("A bare bool flag didn't set the setting")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := load(t, nil, map[string]string{
+		"JWT_SECRET":          "",
+		"PORT":                "0",
+		"PASSWORD_MAX_LENGTH": "4",
+		"TRACING_EXPORTER":    "otlp",
+		"ARGON2_MEMORY_KIB":   "4294967296",
+	})
+	if err == nil {
+		t.Fatal("Load() accepted an invalid config")
+	}
+	for _, setting := range []string{"auth.jwt_secret", "server.port", "passwords.max_length", "passwords.argon2_memory_kib", "tracing.otlp_endpoint"} {
+		if !strings.Contains(err.Error(), setting) {
+			t.Errorf("Error doesn't mention %s: %v", setting, err)
+		}
+	}
+
+	if _, err := load(t, nil, map[string]string{"READ_TIMEOUT": "ten seconds"}); err == nil {
+		t.Error("Load() accepted an unparsable duration")
+	}
+
+	// Secrets can't be passed as flags
+	if _, err := load(t, []string{"-auth-jwt-secret", "x"}, nil); err == nil {
+		t.Error("Load() accepted a secret as a flag")
+	}
+
+	path := filepath.Join(t.TempDir(), "chirpy.yaml")
+	os.WriteFile(path, []byte("server:\n  prot: 9000\n"), 0o600)
+	if _, err := load(t, []string{"-config", path}, nil); err == nil {
+		t.Error("Load() accepted an unknown key in the config file")
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg, err := load(t, nil, nil)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	var out strings.Builder
+	cfg.Print(&out)
+	printed := out.String()
+
+	for _, secret := range []string{"jwt-secret", "polka-old", "mfa-key", "hunter2"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("Printed config contains %q:\n%s", secret, printed)
+		}
+	}
+	for _, line := range []string{
+		"server.port = 8080",
+		"auth.jwt_secret = ****",
+		"database.url = postgres://chirpy:xxxxx@db:5432/chirpy",
+	} {
+		if !strings.Contains(printed, line+"\n") {
+			t.Errorf("Printed config is missing %q:\n%s", line, printed)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const masked = "****"
+
+// field is one setting, found by walking the Config struct tags. It doubles
+// as the flag.Value for its command-line flag, which only records the raw
+// value so that flags can be applied after the file and the environment.
+type field struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+	raw    string
+}
+
+func (c *Config) fields() []*field {
+	var fields []*field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			path := prefix + sf.Tag.Get("yaml")
+			if sf.Type.Kind() == reflect.Struct && sf.Tag.Get("env") == "" {
+				walk(v.Field(i), path+".")
+				continue
+			}
+			fields = append(fields, &field{
+				path:   path,
+				env:    sf.Tag.Get("env"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return fields
+}
+
+func (f *field) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.path)
+}
+
+// Set validates a flag value without applying it
+func (f *field) Set(s string) error {
+	err := parseInto(reflect.New(f.value.Type()).Elem(), s)
+	if err != nil {
+		return err
+	}
+	f.raw = s
+	return nil
+}
+
+func (f *field) set(s string) error {
+	return parseInto(f.value, s)
+}
+
+// String is the current value, with secrets masked. The flag package also
+// calls it on a zero field to decide whether to print a default.
+func (f *field) String() string {
+	if f == nil || !f.value.IsValid() {
+		return ""
+	}
+	s := format(f.value)
+	if f.secret && s != "" {
+		return mask(s)
+	}
+	return s
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// parseInto sets v from its environment or flag form. Lists are comma
+// separated, with blank entries dropped.
+func parseInto(v reflect.Value, s string) error {
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range v.Len() {
+			items[i] = format(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// mask hides a secret. URLs keep everything but the password, so the
+// database host is still visible.
+func mask(s string) string {
+	u, err := url.Parse(s)
+	if err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return masked
+}
+
+// IsBoolFlag lets bool settings be given as a bare -flag
+func (f *field) IsBoolFlag() bool {
+	return f != nil && f.value.IsValid() && f.value.Kind() == reflect.Bool
+}
+
+// Print writes the effective config, one setting per line, with secrets
+// masked.
+func (c *Config) Print(w io.Writer) {
+	for _, f := range c.fields() {
+		fmt.Fprintf(w, "%s = %s\n", f.path, f.String())
+	}
+}
+
+// LogValue logs the config with secrets masked
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, f := range c.fields() {
+		attrs = append(attrs, slog.String(f.path, f.String()))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -108,3 +108,72 @@ func (q *Queries) ListChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const listChirpsForUser = `-- name: ListChirpsForUser :many
+SELECT
+	id, created_at, updated_at, body, user_id
+FROM
+	chirps
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC
+`
+
+func (q *Queries) ListChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+SET
+	updated_at = now(),
+	body = $2
+WHERE
+	id = $1
+RETURNING
+	id, created_at, updated_at, body, user_id
+`
+
+type UpdateChirpParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
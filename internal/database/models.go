@@ -6,11 +6,35 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountLockout struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.NullUUID
+	Ip          string
+	FailedCount int32
+	LockedUntil time.Time
+}
+
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	HashedKey  string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -19,6 +43,67 @@ type Chirp struct {
 	UserID    uuid.UUID
 }
 
+type EmailVerificationToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type FailedLogin struct {
+	UserID       uuid.UUID
+	FailedCount  int32
+	LastFailedAt time.Time
+	LockedUntil  sql.NullTime
+}
+
+type MfaChallenge struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	HashedSecret sql.NullString
+	RedirectUris []string
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -26,13 +111,87 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	ClientID  sql.NullString
+}
+
+type Subscription struct {
+	UserID             uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+}
+
+type SubscriptionEvent struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UserID             uuid.UUID
+	Event              string
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	EmailVerifiedAt     sql.NullTime
+	DeletionRequestedAt sql.NullTime
+}
+
+type UserMfa struct {
+	UserID          uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	EncryptedSecret string
+	EnabledAt       sql.NullTime
+	LastTotpStep    sql.NullInt64
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	EndpointID    uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastAttemptAt sql.NullTime
+	ResponseCode  sql.NullInt32
+	Error         sql.NullString
+	DeliveredAt   sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Events    []string
+	Secret    string
+}
+
+type WebhookEvent struct {
+	ID           string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Source       string
+	Event        string
+	Headers      json.RawMessage
+	Body         []byte
+	Status       string
+	Attempts     int32
+	ResponseCode sql.NullInt32
+	Error        sql.NullString
+	ProcessingMs sql.NullInt32
+	ProcessedAt  sql.NullTime
 }
@@ -26,7 +26,7 @@ INSERT INTO
 VALUES
 	($1, now(), now(), $2, $3, $4)
 RETURNING
-	token, created_at, updated_at, user_id, expires_at, revoked_at
+	token, created_at, updated_at, user_id, expires_at, revoked_at, client_id
 `
 
 type CreateRefreshTokenParams struct {
@@ -51,18 +51,20 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
 SELECT
-	users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red
+	users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.email_verified_at, users.deletion_requested_at
 FROM
 	users
 	JOIN refresh_tokens ON refresh_tokens.user_id = users.id
 WHERE
 	refresh_tokens.token = $1
+	AND refresh_tokens.client_id IS NULL
 	AND revoked_at IS NULL
 	AND expires_at > NOW()
 `
@@ -76,11 +78,69 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
 
+const listRefreshTokensForUser = `-- name: ListRefreshTokensForUser :many
+SELECT
+	token, created_at, updated_at, user_id, expires_at, revoked_at, client_id
+FROM
+	refresh_tokens
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC
+`
+
+func (q *Queries) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, listRefreshTokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.ClientID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET
+	updated_at = now(),
+	revoked_at = now()
+WHERE
+	user_id = $1
+	AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, userID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :one
 UPDATE refresh_tokens
 SET
@@ -88,8 +148,9 @@ SET
 	revoked_at = now()
 WHERE
 	token = $1
+	AND client_id IS NULL
 RETURNING
-	token, created_at, updated_at, user_id, expires_at, revoked_at
+	token, created_at, updated_at, user_id, expires_at, revoked_at, client_id
 `
 
 func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -102,6 +163,7 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (Refresh
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
 	)
 	return i, err
 }
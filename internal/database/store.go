@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrUniqueViolation is returned by Store implementations other than
+// Postgres when a write would break a unique constraint, such as a second
+// user with the same email. Postgres returns its own error, code 23505.
+var ErrUniqueViolation = errors.New("unique constraint violated")
+
+// Store is the storage the core API needs: users, chirps, refresh tokens,
+// login protection, and Chirpy Red subscriptions with the Polka webhook
+// events that change them. Lookups that find nothing return sql.ErrNoRows,
+// as the generated queries do.
+type Store interface {
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteAllUsers(ctx context.Context) error
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
+
+	CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error)
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+	DetailChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	ListChirps(ctx context.Context) ([]Chirp, error)
+	ListChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error)
+	UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error)
+
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
+	GetUserFromRefreshToken(ctx context.Context, token string) (User, error)
+	ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]RefreshToken, error)
+	RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error
+	RevokeRefreshToken(ctx context.Context, token string) (RefreshToken, error)
+
+	ClearFailedLogins(ctx context.Context, userID uuid.UUID) error
+	CreateAccountLockout(ctx context.Context, arg CreateAccountLockoutParams) error
+	GetFailedLogins(ctx context.Context, userID uuid.UUID) (FailedLogin, error)
+	LockAccount(ctx context.Context, arg LockAccountParams) error
+	RecordFailedLogin(ctx context.Context, userID uuid.UUID) (FailedLogin, error)
+
+	GetActivePlan(ctx context.Context, userID uuid.UUID) (string, error)
+	GetSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error)
+	SetSubscription(ctx context.Context, arg SetSubscriptionParams) (Subscription, error)
+
+	ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error)
+	FinishWebhookEvent(ctx context.Context, arg FinishWebhookEventParams) error
+}
+
+var _ Store = (*Queries)(nil)
@@ -7,10 +7,38 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :one
+UPDATE users
+SET
+	updated_at = now(),
+	deletion_requested_at = NULL
+WHERE
+	id = $1
+	AND deletion_requested_at IS NOT NULL
+RETURNING
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, cancelUserDeletion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO
 	users (
@@ -23,7 +51,7 @@ INSERT INTO
 VALUES
 	(gen_random_uuid(), now(), now(), $1, $2)
 RETURNING
-	id, created_at, updated_at, email, hashed_password, is_chirpy_red
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
 `
 
 type CreateUserParams struct {
@@ -40,7 +68,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
@@ -54,13 +83,24 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE
+	id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
 SELECT
-	id, created_at, updated_at, email, hashed_password, is_chirpy_red
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
 FROM
 	users
 WHERE
-	email = $1
+	lower(email) = lower($1)
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -72,7 +112,91 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
+FROM
+	users
+WHERE
+	id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
+
+const listUsersPendingDeletion = `-- name: ListUsersPendingDeletion :many
+SELECT
+	id
+FROM
+	users
+WHERE
+	deletion_requested_at < $1
+`
+
+func (q *Queries) ListUsersPendingDeletion(ctx context.Context, deletionRequestedAt sql.NullTime) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersPendingDeletion, deletionRequestedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :one
+UPDATE users
+SET
+	updated_at = now(),
+	deletion_requested_at = now()
+WHERE
+	id = $1
+	AND deletion_requested_at IS NULL
+RETURNING
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, requestUserDeletion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
@@ -82,11 +206,15 @@ UPDATE users
 SET
 	updated_at = now(),
 	email = $2,
-	hashed_password = $3
+	hashed_password = $3,
+	email_verified_at = CASE
+		WHEN email = $2 THEN email_verified_at
+		ELSE NULL
+	END
 WHERE
 	id = $1
 RETURNING
-	id, created_at, updated_at, email, hashed_password, is_chirpy_red
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
 `
 
 type UpdateUserParams struct {
@@ -104,21 +232,59 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
 	)
 	return i, err
 }
 
-const upgradeToChirpyRed = `-- name: UpgradeToChirpyRed :exec
+const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users
 SET
 	updated_at = now(),
-	is_chirpy_red = TRUE
+	hashed_password = $2
 WHERE
 	id = $1
 `
 
-func (q *Queries) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeToChirpyRed, id)
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
 	return err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET
+	updated_at = now(),
+	email_verified_at = now()
+WHERE
+	id = $1
+	AND email = $2
+RETURNING
+	id, created_at, updated_at, email, hashed_password, email_verified_at, deletion_requested_at
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.DeletionRequestedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// ErrInvalidAddress -
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// NormalizeAddress validates a bare email address and returns it in the
+// lower-cased form we store, so lookups don't depend on how it was typed.
+// Display names ("Walter <walt@example.com>") are rejected.
+func NormalizeAddress(raw string) (string, error) {
+	trimmed := strings.TrimSpace(raw)
+	addr, err := mail.ParseAddress(trimmed)
+	if err != nil {
+		return "", ErrInvalidAddress
+	}
+	if addr.Name != "" || addr.Address != trimmed {
+		return "", ErrInvalidAddress
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	if at < 1 || at == len(addr.Address)-1 {
+		return "", ErrInvalidAddress
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestNormalizeAddress lower-cases and trims valid addresses
+func TestNormalizeAddress(t *testing.T) {
+	tests := map[string]string{
+		"walt@breakingbad.com":       "walt@breakingbad.com",
+		"Walt@BreakingBad.com":       "walt@breakingbad.com",
+		"  jesse@breakingbad.com \n": "jesse@breakingbad.com",
+		"saul+goodman@example.co.uk": "saul+goodman@example.co.uk",
+	}
+
+	for raw, expected := range tests {
+		got, err := NormalizeAddress(raw)
+		if err != nil {
+			t.Errorf("NormalizeAddress(%q) returned an error: %v", raw, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("NormalizeAddress(%q) = %q, expected %q", raw, got, expected)
+		}
+	}
+}
+
+// TestNormalizeAddressInvalid rejects anything that isn't a bare address
+func TestNormalizeAddressInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"walt",
+		"walt@",
+		"@breakingbad.com",
+		"Walter White <walt@breakingbad.com>",
+		"walt@breakingbad.com, jesse@breakingbad.com",
+	} {
+		_, err := NormalizeAddress(raw)
+		if !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("NormalizeAddress(%q) = %v, expected ErrInvalidAddress", raw, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMemoryMailer captures sent messages
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	msg := Message{To: "walt@breakingbad.com", Subject: "Hello", Body: "Say my name"}
+
+	err := m.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send() returned an error: %v", err)
+	}
+
+	messages := m.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Messages() returned %d messages, expected 1", len(messages))
+	}
+	if messages[0] != msg {
+		t.Errorf("Messages()[0] = %+v, expected %+v", messages[0], msg)
+	}
+}
+
+// TestBuildMessage produces headers followed by a CRLF body
+func TestBuildMessage(t *testing.T) {
+	msg := Message{To: "walt@breakingbad.com", Subject: "Hello", Body: "line one\nline two"}
+	raw := string(buildMessage("chirpy@example.com", msg, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: walt@breakingbad.com\r\n",
+		"Subject: Hello\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("buildMessage() output missing %q:\n%s", want, raw)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP relay. Authentication is only
+// attempted when a username is configured.
+type SMTPMailer struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+// NewSMTPMailer -
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     addr,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+// Send -
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, err := net.SplitHostPort(m.addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.username, m.password, host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, auth, m.from, []string{msg.To}, buildMessage(m.from, msg, time.Now()))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// headerReplacer strips line breaks so values can't inject extra headers
+var headerReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+func buildMessage(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerReplacer.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerReplacer.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerReplacer.Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
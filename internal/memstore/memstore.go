@@ -0,0 +1,517 @@
+// Package memstore is an in-memory database.Store, so handlers can be
+// tested without a database. It mirrors the constraints the schema
+// enforces: emails are unique regardless of case, rows owned by a user need
+// that user to exist, and deleting users cascades.
+package memstore
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store is safe for concurrent use. Every method returns copies, so callers
+// can't change stored rows without going through the Store.
+type Store struct {
+	mu            sync.Mutex
+	users         map[uuid.UUID]database.User
+	chirps        []database.Chirp
+	refreshTokens []database.RefreshToken
+	failedLogins  map[uuid.UUID]database.FailedLogin
+	lockouts      []database.AccountLockout
+	subscriptions map[uuid.UUID]database.Subscription
+	subEvents     []database.SubscriptionEvent
+	webhookEvents map[string]database.WebhookEvent
+}
+
+var _ database.Store = (*Store)(nil)
+
+// New returns an empty Store
+func New() *Store {
+	return &Store{
+		users:         map[uuid.UUID]database.User{},
+		failedLogins:  map[uuid.UUID]database.FailedLogin{},
+		subscriptions: map[uuid.UUID]database.Subscription{},
+		webhookEvents: map[string]database.WebhookEvent{},
+	}
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func nullNow() sql.NullTime {
+	return sql.NullTime{Time: now(), Valid: true}
+}
+
+// emailTaken reports whether another user has email, ignoring case. The
+// caller must hold mu.
+func (s *Store) emailTaken(email string, except uuid.UUID) bool {
+	for _, u := range s.users {
+		if u.ID != except && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) requireUser(id uuid.UUID) error {
+	if _, ok := s.users[id]; !ok {
+		return fmt.Errorf("memstore: user %s doesn't exist", id)
+	}
+	return nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, database.ErrUniqueViolation
+	}
+	t := now()
+	u := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+	}
+	s.users[u.ID] = u
+	return u, nil
+}
+
+func (s *Store) DeleteAllUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.users)
+	clear(s.failedLogins)
+	clear(s.subscriptions)
+	s.chirps = nil
+	s.refreshTokens = nil
+	s.subEvents = nil
+	for i := range s.lockouts {
+		s.lockouts[i].UserID = uuid.NullUUID{}
+	}
+	return nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[arg.ID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	if s.emailTaken(arg.Email, arg.ID) {
+		return database.User{}, database.ErrUniqueViolation
+	}
+	// A new address has to be verified again
+	if u.Email != arg.Email {
+		u.EmailVerifiedAt = sql.NullTime{}
+	}
+	u.UpdatedAt = now()
+	u.Email = arg.Email
+	u.HashedPassword = arg.HashedPassword
+	s.users[u.ID] = u
+	return u, nil
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[arg.ID]
+	if !ok {
+		return nil
+	}
+	u.UpdatedAt = now()
+	u.HashedPassword = arg.HashedPassword
+	s.users[u.ID] = u
+	return nil
+}
+
+func (s *Store) VerifyUserEmail(ctx context.Context, arg database.VerifyUserEmailParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[arg.ID]
+	if !ok || u.Email != arg.Email {
+		return database.User{}, sql.ErrNoRows
+	}
+	u.UpdatedAt = now()
+	u.EmailVerifiedAt = nullNow()
+	s.users[u.ID] = u
+	return u, nil
+}
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireUser(arg.UserID); err != nil {
+		return database.Chirp{}, err
+	}
+	t := now()
+	c := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	}
+	s.chirps = append(s.chirps, c)
+	return c, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chirps = slices.DeleteFunc(s.chirps, func(c database.Chirp) bool {
+		return c.ID == id
+	})
+	return nil
+}
+
+func (s *Store) DetailChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.chirps {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+// ListChirps returns chirps oldest first. They're kept in insertion order,
+// which is also created_at order.
+func (s *Store) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.chirps), nil
+}
+
+func (s *Store) ListChirpsForUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chirps []database.Chirp
+	for _, c := range s.chirps {
+		if c.UserID == userID {
+			chirps = append(chirps, c)
+		}
+	}
+	return chirps, nil
+}
+
+func (s *Store) UpdateChirp(ctx context.Context, arg database.UpdateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.chirps {
+		if c.ID == arg.ID {
+			c.UpdatedAt = now()
+			c.Body = arg.Body
+			s.chirps[i] = c
+			return c, nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireUser(arg.UserID); err != nil {
+		return database.RefreshToken{}, err
+	}
+	for _, rt := range s.refreshTokens {
+		if rt.Token == arg.Token {
+			return database.RefreshToken{}, database.ErrUniqueViolation
+		}
+	}
+	t := now()
+	rt := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		RevokedAt: arg.RevokedAt,
+	}
+	s.refreshTokens = append(s.refreshTokens, rt)
+	return rt, nil
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rt := range s.refreshTokens {
+		if rt.Token == token && !rt.RevokedAt.Valid && rt.ExpiresAt.After(now()) {
+			return s.users[rt.UserID], nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []database.RefreshToken
+	for _, rt := range s.refreshTokens {
+		if rt.UserID == userID {
+			tokens = append(tokens, rt)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *Store) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, rt := range s.refreshTokens {
+		if rt.UserID == userID && !rt.RevokedAt.Valid {
+			rt.UpdatedAt = now()
+			rt.RevokedAt = nullNow()
+			s.refreshTokens[i] = rt
+		}
+	}
+	return nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, rt := range s.refreshTokens {
+		if rt.Token == token {
+			rt.UpdatedAt = now()
+			rt.RevokedAt = nullNow()
+			s.refreshTokens[i] = rt
+			return rt, nil
+		}
+	}
+	return database.RefreshToken{}, sql.ErrNoRows
+}
+
+func (s *Store) ClearFailedLogins(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failedLogins, userID)
+	return nil
+}
+
+func (s *Store) CreateAccountLockout(ctx context.Context, arg database.CreateAccountLockoutParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if arg.UserID.Valid {
+		if err := s.requireUser(arg.UserID.UUID); err != nil {
+			return err
+		}
+	}
+	s.lockouts = append(s.lockouts, database.AccountLockout{
+		ID:          uuid.New(),
+		CreatedAt:   now(),
+		UserID:      arg.UserID,
+		Ip:          arg.Ip,
+		FailedCount: arg.FailedCount,
+		LockedUntil: arg.LockedUntil,
+	})
+	return nil
+}
+
+func (s *Store) GetFailedLogins(ctx context.Context, userID uuid.UUID) (database.FailedLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failedLogins[userID]
+	if !ok {
+		return database.FailedLogin{}, sql.ErrNoRows
+	}
+	return f, nil
+}
+
+func (s *Store) LockAccount(ctx context.Context, arg database.LockAccountParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failedLogins[arg.UserID]
+	if !ok {
+		return nil
+	}
+	f.LockedUntil = arg.LockedUntil
+	s.failedLogins[arg.UserID] = f
+	return nil
+}
+
+func (s *Store) RecordFailedLogin(ctx context.Context, userID uuid.UUID) (database.FailedLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireUser(userID); err != nil {
+		return database.FailedLogin{}, err
+	}
+	f, ok := s.failedLogins[userID]
+	if !ok {
+		f = database.FailedLogin{UserID: userID}
+	}
+	f.FailedCount++
+	f.LastFailedAt = now()
+	s.failedLogins[userID] = f
+	return f, nil
+}
+
+// GetActivePlan finds the plan of a subscription that still grants access.
+// Canceled subscriptions last until the end of the paid period.
+func (s *Store) GetActivePlan(ctx context.Context, userID uuid.UUID) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[userID]
+	if !ok || !sub.CurrentPeriodEnd.After(now()) {
+		return "", sql.ErrNoRows
+	}
+	switch sub.Status {
+	case "active", "past_due", "canceled":
+		return sub.Plan, nil
+	}
+	return "", sql.ErrNoRows
+}
+
+func (s *Store) GetSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[userID]
+	if !ok {
+		return database.Subscription{}, sql.ErrNoRows
+	}
+	return sub, nil
+}
+
+// SetSubscription updates the subscription and records the change in its
+// history together.
+func (s *Store) SetSubscription(ctx context.Context, arg database.SetSubscriptionParams) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireUser(arg.UserID); err != nil {
+		return database.Subscription{}, err
+	}
+	t := now()
+	sub, ok := s.subscriptions[arg.UserID]
+	if !ok {
+		sub = database.Subscription{UserID: arg.UserID, CreatedAt: t}
+	}
+	sub.UpdatedAt = t
+	sub.Plan = arg.Plan
+	sub.Status = arg.Status
+	sub.CurrentPeriodStart = arg.CurrentPeriodStart
+	sub.CurrentPeriodEnd = arg.CurrentPeriodEnd
+	s.subscriptions[arg.UserID] = sub
+	s.subEvents = append(s.subEvents, database.SubscriptionEvent{
+		ID:                 uuid.New(),
+		CreatedAt:          t,
+		UserID:             arg.UserID,
+		Event:              arg.Event,
+		Plan:               sub.Plan,
+		Status:             sub.Status,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+	})
+	return sub, nil
+}
+
+// webhookClaimTimeout is how long an event can be processing before another
+// delivery may take it over, as in the Postgres query
+const webhookClaimTimeout = 5 * time.Minute
+
+// ClaimWebhookEvent records a new event, or takes over one that failed or
+// whose processing stalled. Any other event has already been handled, so
+// it finds nothing.
+func (s *Store) ClaimWebhookEvent(ctx context.Context, arg database.ClaimWebhookEventParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	e, ok := s.webhookEvents[arg.ID]
+	switch {
+	case !ok:
+		e = database.WebhookEvent{
+			ID:        arg.ID,
+			CreatedAt: t,
+			Source:    arg.Source,
+			Event:     arg.Event,
+		}
+	case e.Status == "failed", e.Status == "processing" && e.UpdatedAt.Before(t.Add(-webhookClaimTimeout)):
+	default:
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	e.UpdatedAt = t
+	e.Headers = slices.Clone(arg.Headers)
+	e.Body = slices.Clone(arg.Body)
+	e.Status = "processing"
+	e.Attempts++
+	s.webhookEvents[e.ID] = e
+	return e, nil
+}
+
+func (s *Store) FinishWebhookEvent(ctx context.Context, arg database.FinishWebhookEventParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.webhookEvents[arg.ID]
+	if !ok {
+		return nil
+	}
+	e.UpdatedAt = now()
+	e.ProcessedAt = nullNow()
+	e.Status = arg.Status
+	e.ResponseCode = arg.ResponseCode
+	e.Error = arg.Error
+	e.ProcessingMs = arg.ProcessingMs
+	s.webhookEvents[e.ID] = e
+	return nil
+}
@@ -0,0 +1,212 @@
+package memstore
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func createUser(t *testing.T, s *Store, email string) database.User {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q) returned an error: %v", email, err)
+	}
+	return u
+}
+
+// TestUsers checks the constraints the users table enforces
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "Walt@BreakingBad.com"})
+	if !errors.Is(err, database.ErrUniqueViolation) {
+		t.Errorf("Duplicate email returned %v, expected ErrUniqueViolation", err)
+	}
+
+	got, err := s.GetUserByEmail(ctx, "WALT@breakingbad.com")
+	if err != nil || got.ID != walt.ID {
+		t.Errorf("GetUserByEmail = %v, %v, expected walt", got.ID, err)
+	}
+	_, err = s.GetUserByID(ctx, uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByID of a missing user returned %v, expected sql.ErrNoRows", err)
+	}
+
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: jesse.ID, Email: "walt@breakingbad.com"})
+	if !errors.Is(err, database.ErrUniqueViolation) {
+		t.Errorf("Updating to a taken email returned %v, expected ErrUniqueViolation", err)
+	}
+
+	verified, err := s.VerifyUserEmail(ctx, database.VerifyUserEmailParams{ID: walt.ID, Email: walt.Email})
+	if err != nil || !verified.EmailVerifiedAt.Valid {
+		t.Fatalf("VerifyUserEmail = %v, %v, expected a verified user", verified.EmailVerifiedAt, err)
+	}
+	updated, err := s.UpdateUser(ctx, database.UpdateUserParams{ID: walt.ID, Email: walt.Email, HashedPassword: "new"})
+	if err != nil || !updated.EmailVerifiedAt.Valid {
+		t.Errorf("Keeping the same email unverified the user: %v, %v", updated.EmailVerifiedAt, err)
+	}
+	updated, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: walt.ID, Email: "heisenberg@breakingbad.com"})
+	if err != nil || updated.EmailVerifiedAt.Valid {
+		t.Errorf("Changing the email kept it verified: %v, %v", updated.EmailVerifiedAt, err)
+	}
+}
+
+// TestChirps checks ordering, updates and that chirps need an author
+func TestChirps(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	_, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "orphan", UserID: uuid.New()})
+	if err == nil {
+		t.Error("CreateChirp accepted a missing user")
+	}
+
+	var ids []uuid.UUID
+	for _, author := range []uuid.UUID{walt.ID, jesse.ID, walt.ID} {
+		c, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: author})
+		if err != nil {
+			t.Fatalf("CreateChirp returned an error: %v", err)
+		}
+		ids = append(ids, c.ID)
+	}
+
+	all, _ := s.ListChirps(ctx)
+	if len(all) != 3 || all[0].ID != ids[0] || all[2].ID != ids[2] {
+		t.Errorf("ListChirps isn't in creation order: %v", all)
+	}
+	mine, _ := s.ListChirpsForUser(ctx, walt.ID)
+	if len(mine) != 2 {
+		t.Errorf("ListChirpsForUser returned %d chirps, expected 2", len(mine))
+	}
+
+	updated, err := s.UpdateChirp(ctx, database.UpdateChirpParams{ID: ids[1], Body: "edited"})
+	if err != nil || updated.Body != "edited" {
+		t.Errorf("UpdateChirp = %q, %v", updated.Body, err)
+	}
+	err = s.DeleteChirp(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("DeleteChirp returned an error: %v", err)
+	}
+	_, err = s.DetailChirp(ctx, ids[1])
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DetailChirp of a deleted chirp returned %v, expected sql.ErrNoRows", err)
+	}
+}
+
+// TestRefreshTokens checks that revoked and expired tokens don't find a user
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	for _, token := range []string{"a", "b"} {
+		_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			Token:     token,
+			UserID:    walt.ID,
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("CreateRefreshToken returned an error: %v", err)
+		}
+	}
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "expired",
+		UserID:    walt.ID,
+		ExpiresAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken returned an error: %v", err)
+	}
+
+	u, err := s.GetUserFromRefreshToken(ctx, "a")
+	if err != nil || u.ID != walt.ID {
+		t.Errorf("GetUserFromRefreshToken = %v, %v, expected walt", u.ID, err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "expired")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expired token returned %v, expected sql.ErrNoRows", err)
+	}
+
+	_, err = s.RevokeRefreshToken(ctx, "a")
+	if err != nil {
+		t.Fatalf("RevokeRefreshToken returned an error: %v", err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "a")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Revoked token returned %v, expected sql.ErrNoRows", err)
+	}
+
+	err = s.RevokeAllRefreshTokensForUser(ctx, walt.ID)
+	if err != nil {
+		t.Fatalf("RevokeAllRefreshTokensForUser returned an error: %v", err)
+	}
+	tokens, _ := s.ListRefreshTokensForUser(ctx, walt.ID)
+	for _, rt := range tokens {
+		if !rt.RevokedAt.Valid {
+			t.Errorf("Token %q wasn't revoked", rt.Token)
+		}
+	}
+}
+
+// TestDeleteAllUsers cascades to everything the users own
+func TestDeleteAllUsers(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	walt := createUser(t, s, "walt@breakingbad.com")
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: walt.ID})
+	s.RecordFailedLogin(ctx, walt.ID)
+
+	err := s.DeleteAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("DeleteAllUsers returned an error: %v", err)
+	}
+	chirps, _ := s.ListChirps(ctx)
+	if len(chirps) != 0 {
+		t.Errorf("%d chirps survived DeleteAllUsers", len(chirps))
+	}
+	_, err = s.GetFailedLogins(ctx, walt.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Failed logins survived DeleteAllUsers: %v", err)
+	}
+}
+
+// TestConcurrentUse is meant for go test -race
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: walt.ID})
+			s.ListChirps(ctx)
+			s.RecordFailedLogin(ctx, walt.ID)
+		})
+	}
+	wg.Wait()
+
+	chirps, _ := s.ListChirps(ctx)
+	if len(chirps) != 20 {
+		t.Errorf("Got %d chirps, expected 20", len(chirps))
+	}
+	f, _ := s.GetFailedLogins(ctx, walt.ID)
+	if f.FailedCount != 20 {
+		t.Errorf("FailedCount = %d, expected 20", f.FailedCount)
+	}
+}
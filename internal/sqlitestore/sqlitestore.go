@@ -0,0 +1,414 @@
+// Package sqlitestore is a database.Store on SQLite, for single-node installs
+// that don't want to run Postgres. Its queries are generated into
+// internal/sqlitedb from sql/sqlite. This package converts between their
+// types and the database package's, and supplies the IDs and timestamps
+// Postgres generates itself.
+package sqlitestore
+
+import (
+	"chirpy/internal/database"
+	"chirpy/internal/sqlitedb"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// webhookClaimTimeout is how long an event can be processing before another
+// delivery may take it over, as in the Postgres query
+const webhookClaimTimeout = 5 * time.Minute
+
+// DSN turns a sqlite:path URL into a go-sqlite3 data source name. Both
+// sqlite:chirpy.db and sqlite:///var/lib/chirpy/chirpy.db work, and query
+// parameters are passed to the driver. Foreign keys are always enforced,
+// since deleting users relies on cascades.
+func DSN(dbURL string) (string, error) {
+	rest, ok := strings.CutPrefix(dbURL, "sqlite:")
+	if !ok {
+		return "", errors.New("database URL doesn't start with sqlite:")
+	}
+	rest = strings.TrimPrefix(rest, "//")
+	path, rawQuery, _ := strings.Cut(rest, "?")
+	if path == "" {
+		return "", errors.New("database URL has no file path")
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse database URL parameters: %w", err)
+	}
+
+	query.Set("_foreign_keys", "1")
+	if !query.Has("_busy_timeout") {
+		query.Set("_busy_timeout", "5000")
+	}
+	if !query.Has("_journal_mode") {
+		query.Set("_journal_mode", "WAL")
+	}
+	return "file:" + path + "?" + query.Encode(), nil
+}
+
+// Open opens the database a sqlite:path URL points at, creating the file if
+// it doesn't exist.
+func Open(dbURL string) (*sql.DB, error) {
+	dsn, err := DSN(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has one writer at a time. A single connection queues writes in
+	// database/sql rather than failing them with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// Store is safe for concurrent use
+type Store struct {
+	db   *sql.DB
+	wrap func(sqlitedb.DBTX) sqlitedb.DBTX
+	q    *sqlitedb.Queries
+}
+
+var _ database.Store = (*Store)(nil)
+
+// New returns a Store running its queries on db, which must be migrated
+// with the sql/sqlite/schema migrations. If wrap isn't nil, queries run on
+// what it returns for db, or for a transaction, e.g. to trace them.
+func New(db *sql.DB, wrap func(sqlitedb.DBTX) sqlitedb.DBTX) *Store {
+	if wrap == nil {
+		wrap = func(db sqlitedb.DBTX) sqlitedb.DBTX { return db }
+	}
+	return &Store{db: db, wrap: wrap, q: sqlitedb.New(wrap(db))}
+}
+
+// now is stored in UTC, so timestamps compare correctly as text
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func nullUTC(t sql.NullTime) sql.NullTime {
+	t.Time = t.Time.UTC()
+	return t
+}
+
+// translateErr makes unique constraint failures match
+// database.ErrUniqueViolation
+func translateErr(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return fmt.Errorf("%w: %w", database.ErrUniqueViolation, err)
+	}
+	return err
+}
+
+func fromUser(u sqlitedb.User, err error) (database.User, error) {
+	return database.User{
+		ID:              u.ID,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+		Email:           u.Email,
+		HashedPassword:  u.HashedPassword,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+	}, translateErr(err)
+}
+
+func fromChirp(c sqlitedb.Chirp, err error) (database.Chirp, error) {
+	return database.Chirp(c), translateErr(err)
+}
+
+func fromChirps(chirps []sqlitedb.Chirp, err error) ([]database.Chirp, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.Chirp, len(chirps))
+	for i, c := range chirps {
+		items[i] = database.Chirp(c)
+	}
+	return items, nil
+}
+
+func fromRefreshToken(rt sqlitedb.RefreshToken, err error) (database.RefreshToken, error) {
+	return database.RefreshToken{
+		Token:     rt.Token,
+		CreatedAt: rt.CreatedAt,
+		UpdatedAt: rt.UpdatedAt,
+		UserID:    rt.UserID,
+		ExpiresAt: rt.ExpiresAt,
+		RevokedAt: rt.RevokedAt,
+	}, translateErr(err)
+}
+
+func fromFailedLogin(f sqlitedb.FailedLogin, err error) (database.FailedLogin, error) {
+	return database.FailedLogin{
+		UserID:       f.UserID,
+		FailedCount:  int32(f.FailedCount),
+		LastFailedAt: f.LastFailedAt,
+		LockedUntil:  f.LockedUntil,
+	}, err
+}
+
+func fromSubscription(s sqlitedb.Subscription, err error) (database.Subscription, error) {
+	return database.Subscription(s), err
+}
+
+func fromWebhookEvent(e sqlitedb.WebhookEvent, err error) (database.WebhookEvent, error) {
+	return database.WebhookEvent{
+		ID:           e.ID,
+		CreatedAt:    e.CreatedAt,
+		UpdatedAt:    e.UpdatedAt,
+		Source:       e.Source,
+		Event:        e.Event,
+		Headers:      []byte(e.Headers),
+		Body:         e.Body,
+		Status:       e.Status,
+		Attempts:     int32(e.Attempts),
+		ResponseCode: sql.NullInt32{Int32: int32(e.ResponseCode.Int64), Valid: e.ResponseCode.Valid},
+		Error:        e.Error,
+		ProcessingMs: sql.NullInt32{Int32: int32(e.ProcessingMs.Int64), Valid: e.ProcessingMs.Valid},
+		ProcessedAt:  e.ProcessedAt,
+	}, err
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	return fromUser(s.q.CreateUser(ctx, sqlitedb.CreateUserParams{
+		ID:             uuid.New(),
+		Now:            now(),
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+	}))
+}
+
+func (s *Store) DeleteAllUsers(ctx context.Context) error {
+	return s.q.DeleteAllUsers(ctx)
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	return fromUser(s.q.GetUserByEmail(ctx, email))
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return fromUser(s.q.GetUserByID(ctx, id))
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	return fromUser(s.q.UpdateUser(ctx, sqlitedb.UpdateUserParams{
+		Now:            now(),
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		ID:             arg.ID,
+	}))
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	return s.q.UpdateUserPassword(ctx, sqlitedb.UpdateUserPasswordParams{
+		Now:            now(),
+		HashedPassword: arg.HashedPassword,
+		ID:             arg.ID,
+	})
+}
+
+func (s *Store) VerifyUserEmail(ctx context.Context, arg database.VerifyUserEmailParams) (database.User, error) {
+	return fromUser(s.q.VerifyUserEmail(ctx, sqlitedb.VerifyUserEmailParams{
+		Now:   now(),
+		ID:    arg.ID,
+		Email: arg.Email,
+	}))
+}
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	return fromChirp(s.q.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+		ID:     uuid.New(),
+		Now:    now(),
+		Body:   arg.Body,
+		UserID: arg.UserID,
+	}))
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirp(ctx, id)
+}
+
+func (s *Store) DetailChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return fromChirp(s.q.DetailChirp(ctx, id))
+}
+
+func (s *Store) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+	return fromChirps(s.q.ListChirps(ctx))
+}
+
+func (s *Store) ListChirpsForUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return fromChirps(s.q.ListChirpsForUser(ctx, userID))
+}
+
+func (s *Store) UpdateChirp(ctx context.Context, arg database.UpdateChirpParams) (database.Chirp, error) {
+	return fromChirp(s.q.UpdateChirp(ctx, sqlitedb.UpdateChirpParams{
+		Now:  now(),
+		Body: arg.Body,
+		ID:   arg.ID,
+	}))
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	return fromRefreshToken(s.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams{
+		Token:     arg.Token,
+		Now:       now(),
+		UserID:    arg.UserID,
+		ExpiresAt: utc(arg.ExpiresAt),
+		RevokedAt: nullUTC(arg.RevokedAt),
+	}))
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	return fromUser(s.q.GetUserFromRefreshToken(ctx, sqlitedb.GetUserFromRefreshTokenParams{
+		Token: token,
+		Now:   now(),
+	}))
+}
+
+func (s *Store) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	tokens, err := s.q.ListRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]database.RefreshToken, len(tokens))
+	for i, rt := range tokens {
+		items[i], _ = fromRefreshToken(rt, nil)
+	}
+	return items, nil
+}
+
+func (s *Store) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	return s.q.RevokeAllRefreshTokensForUser(ctx, sqlitedb.RevokeAllRefreshTokensForUserParams{
+		Now:    now(),
+		UserID: userID,
+	})
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	return fromRefreshToken(s.q.RevokeRefreshToken(ctx, sqlitedb.RevokeRefreshTokenParams{
+		Now:   now(),
+		Token: token,
+	}))
+}
+
+func (s *Store) ClearFailedLogins(ctx context.Context, userID uuid.UUID) error {
+	return s.q.ClearFailedLogins(ctx, userID)
+}
+
+func (s *Store) CreateAccountLockout(ctx context.Context, arg database.CreateAccountLockoutParams) error {
+	return s.q.CreateAccountLockout(ctx, sqlitedb.CreateAccountLockoutParams{
+		ID:          uuid.New(),
+		Now:         now(),
+		UserID:      arg.UserID,
+		Ip:          arg.Ip,
+		FailedCount: int64(arg.FailedCount),
+		LockedUntil: utc(arg.LockedUntil),
+	})
+}
+
+func (s *Store) GetFailedLogins(ctx context.Context, userID uuid.UUID) (database.FailedLogin, error) {
+	return fromFailedLogin(s.q.GetFailedLogins(ctx, userID))
+}
+
+func (s *Store) LockAccount(ctx context.Context, arg database.LockAccountParams) error {
+	return s.q.LockAccount(ctx, sqlitedb.LockAccountParams{
+		LockedUntil: nullUTC(arg.LockedUntil),
+		UserID:      arg.UserID,
+	})
+}
+
+func (s *Store) RecordFailedLogin(ctx context.Context, userID uuid.UUID) (database.FailedLogin, error) {
+	return fromFailedLogin(s.q.RecordFailedLogin(ctx, sqlitedb.RecordFailedLoginParams{
+		UserID: userID,
+		Now:    now(),
+	}))
+}
+
+func (s *Store) GetActivePlan(ctx context.Context, userID uuid.UUID) (string, error) {
+	return s.q.GetActivePlan(ctx, sqlitedb.GetActivePlanParams{
+		UserID: userID,
+		Now:    now(),
+	})
+}
+
+func (s *Store) GetSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	return fromSubscription(s.q.GetSubscription(ctx, userID))
+}
+
+// SetSubscription updates the subscription and records the change in its
+// history in one transaction
+func (s *Store) SetSubscription(ctx context.Context, arg database.SetSubscriptionParams) (database.Subscription, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	defer tx.Rollback()
+	q := sqlitedb.New(s.wrap(tx))
+
+	t := now()
+	sub, err := fromSubscription(q.UpsertSubscription(ctx, sqlitedb.UpsertSubscriptionParams{
+		UserID:             arg.UserID,
+		Now:                t,
+		Plan:               arg.Plan,
+		Status:             arg.Status,
+		CurrentPeriodStart: utc(arg.CurrentPeriodStart),
+		CurrentPeriodEnd:   utc(arg.CurrentPeriodEnd),
+	}))
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	err = q.CreateSubscriptionEvent(ctx, sqlitedb.CreateSubscriptionEventParams{
+		ID:                 uuid.New(),
+		Now:                t,
+		UserID:             sub.UserID,
+		Event:              arg.Event,
+		Plan:               sub.Plan,
+		Status:             sub.Status,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+	})
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.Subscription{}, err
+	}
+	return sub, nil
+}
+
+func (s *Store) ClaimWebhookEvent(ctx context.Context, arg database.ClaimWebhookEventParams) (database.WebhookEvent, error) {
+	t := now()
+	return fromWebhookEvent(s.q.ClaimWebhookEvent(ctx, sqlitedb.ClaimWebhookEventParams{
+		ID:          arg.ID,
+		Now:         t,
+		Source:      arg.Source,
+		Event:       arg.Event,
+		Headers:     string(arg.Headers),
+		Body:        arg.Body,
+		StaleBefore: t.Add(-webhookClaimTimeout),
+	}))
+}
+
+func (s *Store) FinishWebhookEvent(ctx context.Context, arg database.FinishWebhookEventParams) error {
+	return s.q.FinishWebhookEvent(ctx, sqlitedb.FinishWebhookEventParams{
+		Now:          now(),
+		Status:       arg.Status,
+		ResponseCode: sql.NullInt64{Int64: int64(arg.ResponseCode.Int32), Valid: arg.ResponseCode.Valid},
+		Error:        arg.Error,
+		ProcessingMs: sql.NullInt64{Int64: int64(arg.ProcessingMs.Int32), Valid: arg.ProcessingMs.Valid},
+		ID:           arg.ID,
+	})
+}
@@ -0,0 +1,387 @@
+package sqlitestore
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+)
+
+// newTestStore opens a migrated database in a temporary directory
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	db, err := Open("sqlite:" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db, os.DirFS("../../sql/sqlite/schema"))
+	if err != nil {
+		t.Fatalf("Couldn't load migrations: %v", err)
+	}
+	if _, err := provider.Up(context.Background()); err != nil {
+		t.Fatalf("Couldn't migrate: %v", err)
+	}
+	return New(db, nil)
+}
+
+func createUser(t *testing.T, s *Store, email string) database.User {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q) returned an error: %v", email, err)
+	}
+	return u
+}
+
+func TestDSN(t *testing.T) {
+	tests := map[string]string{
+		"sqlite:chirpy.db":                      "file:chirpy.db?",
+		"sqlite://chirpy.db":                    "file:chirpy.db?",
+		"sqlite:///var/lib/chirpy/chirpy.db":    "file:/var/lib/chirpy/chirpy.db?",
+		"sqlite:chirpy.db?_journal_mode=DELETE": "file:chirpy.db?",
+	}
+	for dbURL, prefix := range tests {
+		dsn, err := DSN(dbURL)
+		if err != nil {
+			t.Errorf("DSN(%q) returned an error: %v", dbURL, err)
+			continue
+		}
+		if !strings.HasPrefix(dsn, prefix) || !strings.Contains(dsn, "_foreign_keys=1") {
+			t.Errorf("DSN(%q) = %q, expected %s... with foreign keys on", dbURL, dsn, prefix)
+		}
+	}
+
+	dsn, _ := DSN("sqlite:chirpy.db?_journal_mode=DELETE")
+	if !strings.Contains(dsn, "_journal_mode=DELETE") {
+		t.Errorf("DSN dropped the journal mode given in the URL: %q", dsn)
+	}
+
+	for _, dbURL := range []string{"postgres://localhost/chirpy", "sqlite:", "sqlite://"} {
+		if _, err := DSN(dbURL); err == nil {
+			t.Errorf("DSN(%q) didn't return an error", dbURL)
+		}
+	}
+}
+
+// TestUsers checks the constraints the users table enforces
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt := createUser(t, s, "walt@breakingbad.com")
+	jesse := createUser(t, s, "jesse@breakingbad.com")
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "Walt@BreakingBad.com"})
+	if !errors.Is(err, database.ErrUniqueViolation) {
+		t.Errorf("Duplicate email returned %v, expected ErrUniqueViolation", err)
+	}
+
+	got, err := s.GetUserByEmail(ctx, "WALT@breakingbad.com")
+	if err != nil || got.ID != walt.ID {
+		t.Errorf("GetUserByEmail = %v, %v, expected walt", got.ID, err)
+	}
+	if !got.CreatedAt.Equal(walt.CreatedAt) {
+		t.Errorf("CreatedAt = %v, expected %v", got.CreatedAt, walt.CreatedAt)
+	}
+	_, err = s.GetUserByID(ctx, uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByID of a missing user returned %v, expected sql.ErrNoRows", err)
+	}
+
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: jesse.ID, Email: "walt@breakingbad.com"})
+	if !errors.Is(err, database.ErrUniqueViolation) {
+		t.Errorf("Updating to a taken email returned %v, expected ErrUniqueViolation", err)
+	}
+
+	verified, err := s.VerifyUserEmail(ctx, database.VerifyUserEmailParams{ID: walt.ID, Email: walt.Email})
+	if err != nil || !verified.EmailVerifiedAt.Valid {
+		t.Fatalf("VerifyUserEmail = %v, %v, expected a verified user", verified.EmailVerifiedAt, err)
+	}
+	updated, err := s.UpdateUser(ctx, database.UpdateUserParams{ID: walt.ID, Email: walt.Email, HashedPassword: "new"})
+	if err != nil || !updated.EmailVerifiedAt.Valid {
+		t.Errorf("Keeping the same email unverified the user: %v, %v", updated.EmailVerifiedAt, err)
+	}
+	updated, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: walt.ID, Email: "heisenberg@breakingbad.com"})
+	if err != nil || updated.EmailVerifiedAt.Valid {
+		t.Errorf("Changing the email kept it verified: %v, %v", updated.EmailVerifiedAt, err)
+	}
+}
+
+// TestChirps checks ordering, foreign keys and cascades
+func TestChirps(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	_, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "orphan", UserID: uuid.New()})
+	if err == nil {
+		t.Error("CreateChirp accepted a missing user")
+	}
+
+	var ids []uuid.UUID
+	for _, body := range []string{"I'm the one who knocks!", "Gale!"} {
+		c, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: walt.ID})
+		if err != nil {
+			t.Fatalf("CreateChirp returned an error: %v", err)
+		}
+		ids = append(ids, c.ID)
+	}
+	chirps, err := s.ListChirps(ctx)
+	if err != nil || len(chirps) != 2 || chirps[0].ID != ids[0] {
+		t.Errorf("ListChirps = %v, %v, expected both, oldest first", chirps, err)
+	}
+
+	updated, err := s.UpdateChirp(ctx, database.UpdateChirpParams{ID: ids[1], Body: "edited"})
+	if err != nil || updated.Body != "edited" {
+		t.Errorf("UpdateChirp = %q, %v", updated.Body, err)
+	}
+
+	err = s.DeleteAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("DeleteAllUsers returned an error: %v", err)
+	}
+	chirps, _ = s.ListChirps(ctx)
+	if len(chirps) != 0 {
+		t.Errorf("%d chirps survived DeleteAllUsers", len(chirps))
+	}
+}
+
+// TestRefreshTokens checks that revoked and expired tokens don't find a
+// user, which depends on timestamps comparing correctly
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	tokens := map[string]time.Duration{"valid": time.Hour, "expired": -time.Second}
+	for token, lifetime := range tokens {
+		_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			Token:  token,
+			UserID: walt.ID,
+			// Not UTC, to check the store normalizes it
+			ExpiresAt: time.Now().In(time.FixedZone("MST", -7*60*60)).Add(lifetime),
+		})
+		if err != nil {
+			t.Fatalf("CreateRefreshToken returned an error: %v", err)
+		}
+	}
+
+	u, err := s.GetUserFromRefreshToken(ctx, "valid")
+	if err != nil || u.ID != walt.ID {
+		t.Errorf("GetUserFromRefreshToken = %v, %v, expected walt", u.ID, err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "expired")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expired token returned %v, expected sql.ErrNoRows", err)
+	}
+
+	_, err = s.RevokeRefreshToken(ctx, "valid")
+	if err != nil {
+		t.Fatalf("RevokeRefreshToken returned an error: %v", err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "valid")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Revoked token returned %v, expected sql.ErrNoRows", err)
+	}
+	_, err = s.RevokeRefreshToken(ctx, "missing")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Revoking a missing token returned %v, expected sql.ErrNoRows", err)
+	}
+}
+
+// TestFailedLogins checks the upsert counting failures
+func TestFailedLogins(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	for range 3 {
+		_, err := s.RecordFailedLogin(ctx, walt.ID)
+		if err != nil {
+			t.Fatalf("RecordFailedLogin returned an error: %v", err)
+		}
+	}
+	until := time.Now().Add(time.Minute)
+	err := s.LockAccount(ctx, database.LockAccountParams{
+		UserID:      walt.ID,
+		LockedUntil: sql.NullTime{Time: until, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("LockAccount returned an error: %v", err)
+	}
+
+	f, err := s.GetFailedLogins(ctx, walt.ID)
+	if err != nil || f.FailedCount != 3 || !f.LockedUntil.Time.Equal(until) {
+		t.Errorf("GetFailedLogins = %+v, %v, expected 3 failures locked until %v", f, err, until)
+	}
+
+	err = s.CreateAccountLockout(ctx, database.CreateAccountLockoutParams{
+		UserID:      uuid.NullUUID{UUID: walt.ID, Valid: true},
+		Ip:          "127.0.0.1",
+		FailedCount: 3,
+		LockedUntil: until,
+	})
+	if err != nil {
+		t.Errorf("CreateAccountLockout returned an error: %v", err)
+	}
+
+	err = s.ClearFailedLogins(ctx, walt.ID)
+	if err != nil {
+		t.Fatalf("ClearFailedLogins returned an error: %v", err)
+	}
+	_, err = s.GetFailedLogins(ctx, walt.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFailedLogins after clearing returned %v, expected sql.ErrNoRows", err)
+	}
+}
+
+// TestSubscriptions checks which subscriptions count as an active plan
+func TestSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	_, err := s.GetActivePlan(ctx, walt.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetActivePlan without a subscription returned %v, expected sql.ErrNoRows", err)
+	}
+
+	start := time.Now()
+	tests := []struct {
+		status string
+		end    time.Time
+		active bool
+	}{
+		{"active", start.Add(time.Hour), true},
+		{"canceled", start.Add(time.Hour), true},
+		{"active", start.Add(-time.Second), false},
+		{"expired", start.Add(time.Hour), false},
+	}
+	for _, tc := range tests {
+		sub, err := s.SetSubscription(ctx, database.SetSubscriptionParams{
+			UserID:             walt.ID,
+			Plan:               "chirpy_red",
+			Status:             tc.status,
+			CurrentPeriodStart: start,
+			CurrentPeriodEnd:   tc.end,
+			Event:              "admin.granted",
+		})
+		if err != nil || sub.Status != tc.status {
+			t.Fatalf("SetSubscription = %+v, %v", sub, err)
+		}
+
+		plan, err := s.GetActivePlan(ctx, walt.ID)
+		if tc.active && (err != nil || plan != "chirpy_red") {
+			t.Errorf("%s until %v: GetActivePlan = %q, %v, expected chirpy_red", tc.status, tc.end, plan, err)
+		}
+		if !tc.active && !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s until %v: GetActivePlan = %q, %v, expected sql.ErrNoRows", tc.status, tc.end, plan, err)
+		}
+	}
+
+	var events int
+	err = s.db.QueryRow("SELECT count(*) FROM subscription_events WHERE user_id = ?", walt.ID).Scan(&events)
+	if err != nil || events != len(tests) {
+		t.Errorf("Found %d subscription events (%v), expected %d", events, err, len(tests))
+	}
+
+	_, err = s.SetSubscription(ctx, database.SetSubscriptionParams{
+		UserID:             uuid.New(),
+		Plan:               "chirpy_red",
+		Status:             "active",
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   start.Add(time.Hour),
+		Event:              "admin.granted",
+	})
+	if err == nil {
+		t.Error("SetSubscription for a missing user didn't return an error")
+	}
+}
+
+// TestClaimWebhookEvent checks that handled events can't be claimed again
+// and failed ones can
+func TestClaimWebhookEvent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	params := database.ClaimWebhookEventParams{
+		ID:      "evt_1",
+		Source:  "polka",
+		Event:   "user.upgraded",
+		Headers: []byte(`{}`),
+		Body:    []byte(`{"id":"evt_1"}`),
+	}
+
+	e, err := s.ClaimWebhookEvent(ctx, params)
+	if err != nil || e.Status != "processing" || e.Attempts != 1 {
+		t.Fatalf("First claim = %+v, %v", e, err)
+	}
+	_, err = s.ClaimWebhookEvent(ctx, params)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Claiming an event being processed returned %v, expected sql.ErrNoRows", err)
+	}
+
+	err = s.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:           "evt_1",
+		Status:       "failed",
+		ResponseCode: sql.NullInt32{Int32: 500, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("FinishWebhookEvent returned an error: %v", err)
+	}
+	e, err = s.ClaimWebhookEvent(ctx, params)
+	if err != nil || e.Attempts != 2 {
+		t.Errorf("Claiming a failed event = %+v, %v, expected a second attempt", e, err)
+	}
+
+	s.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{ID: "evt_1", Status: "processed"})
+	_, err = s.ClaimWebhookEvent(ctx, params)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Claiming a processed event returned %v, expected sql.ErrNoRows", err)
+	}
+}
+
+// TestConcurrentUse checks that concurrent writers queue rather than fail
+// with SQLITE_BUSY
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	walt := createUser(t, s, "walt@breakingbad.com")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Go(func() {
+			_, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: walt.ID})
+			if err == nil {
+				_, err = s.RecordFailedLogin(ctx, walt.ID)
+			}
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent write failed: %v", err)
+		}
+	}
+	f, _ := s.GetFailedLogins(ctx, walt.ID)
+	if f.FailedCount != 20 {
+		t.Errorf("FailedCount = %d, expected 20", f.FailedCount)
+	}
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const dbTracerName = "chirpy/internal/database"
+
+// sqlc starts every query with "-- name: <Name> :<kind>"
+var queryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+type tracedDB struct {
+	db     database.DBTX
+	system attribute.KeyValue
+}
+
+// WrapDB returns a DBTX that records a span for every query, named after the
+// sqlc query. system is the db.system attribute, e.g. "postgresql" or
+// "sqlite". Spans for QueryContext end once the first rows are available,
+// not when they have all been read.
+func WrapDB(db database.DBTX, system string) database.DBTX {
+	return &tracedDB{db: db, system: semconv.DBSystemKey.String(system)}
+}
+
+func (t *tracedDB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := "query"
+	if m := queryName.FindStringSubmatch(query); m != nil {
+		name = m[1]
+	}
+	return otel.Tracer(dbTracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			t.system,
+			semconv.DBOperationName(name),
+			// Parameters are sent separately, so the text holds no user data
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := t.start(ctx, query)
+	result, err := t.db.ExecContext(ctx, query, args...)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := t.start(ctx, query)
+	stmt, err := t.db.PrepareContext(ctx, query)
+	end(span, err)
+	return stmt, err
+}
+
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, query)
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	end(span, err)
+	return rows, err
+}
+
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := t.start(ctx, query)
+	row := t.db.QueryRowContext(ctx, query, args...)
+	end(span, row.Err())
+	return row
+}
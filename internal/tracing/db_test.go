@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type fakeDB struct {
+	err error
+}
+
+func (f *fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f *fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f *fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f *fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+// TestWrapDB names spans after the sqlc query and records failures
+func TestWrapDB(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(provider)
+
+	query := "-- name: DeleteChirp :exec\nDELETE FROM chirps WHERE id = $1"
+	WrapDB(&fakeDB{}, "postgresql").ExecContext(context.Background(), query, 1)
+	WrapDB(&fakeDB{err: errors.New("connection refused")}, "postgresql").ExecContext(context.Background(), query, 1)
+	WrapDB(&fakeDB{err: sql.ErrNoRows}, "sqlite").QueryContext(context.Background(), "SELECT 1")
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Recorded %d spans, expected 3", len(spans))
+	}
+	if spans[0].Name() != "DeleteChirp" {
+		t.Errorf("Span name = %q, expected DeleteChirp", spans[0].Name())
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Error("Successful query has an error status")
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Error("Failed query doesn't have an error status")
+	}
+	if spans[2].Name() != "query" || spans[2].Status().Code == codes.Error {
+		t.Errorf("Span %q has status %v, expected an unnamed query without error", spans[2].Name(), spans[2].Status().Code)
+	}
+	for i, system := range []string{"postgresql", "postgresql", "sqlite"} {
+		if !slices.Contains(spans[i].Attributes(), attribute.String("db.system", system)) {
+			t.Errorf("Span %d doesn't have db.system %s: %v", i, system, spans[i].Attributes())
+		}
+	}
+}
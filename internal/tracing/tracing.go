@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Exporters accepted by Setup
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Config -
+type Config struct {
+	Exporter    string
+	ServiceName string
+	// OTLPEndpoint is the collector's base URL, e.g. http://localhost:4318
+	OTLPEndpoint string
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. With no exporter, spans are never recorded, but incoming
+// traceparent headers are still passed on to outgoing context. The returned
+// function flushes any buffered spans and must be called before exit.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		var err error
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+	case ExporterOTLP:
+		if cfg.OTLPEndpoint == "" {
+			return nil, fmt.Errorf("an OTLP endpoint is required")
+		}
+		// The standard OTEL_EXPORTER_OTLP_* variables for headers, TLS,
+		// compression and timeouts are read by the exporter itself
+		var err error
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTLPEndpoint, "/")+"/v1/traces"),
+		)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
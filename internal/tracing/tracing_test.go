@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+// TestSetupOTLP sends spans to the collector's /v1/traces path with the
+// headers from OTEL_EXPORTER_OTLP_HEADERS
+func TestSetupOTLP(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer collector-token")
+
+	requests := make(chan *http.Request, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- r:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	shutdown, err := Setup(context.Background(), Config{
+		Exporter:     ExporterOTLP,
+		ServiceName:  "chirpy-test",
+		OTLPEndpoint: collector.URL + "/",
+	})
+	if err != nil {
+		t.Fatalf("Setup returned an error: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "GetUser")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Flushing spans returned an error: %v", err)
+	}
+
+	select {
+	case r := <-requests:
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Path = %q, expected /v1/traces", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer collector-token" {
+			t.Errorf("Authorization = %q, expected the configured header", got)
+		}
+	default:
+		t.Fatal("The collector didn't receive any spans")
+	}
+}
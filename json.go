@@ -2,22 +2,27 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
-	}
+// respondWithError logs the cause and sends msg to the client. Logging with
+// the request's context tags the line with the request and trace IDs, and
+// the request ID set by middlewareLog is returned in the body too.
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, msg string, err error) {
+	requestID := requestIDFromContext(r.Context())
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		slog.ErrorContext(r.Context(), msg, "status", code, "error", err)
+	} else if err != nil {
+		slog.InfoContext(r.Context(), msg, "status", code, "error", err)
 	}
 	type errorResponse struct {
-		Error string `json:"error"`
+		Error     string `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
 	}
 	respondWithJSON(w, code, errorResponse{
-		Error: msg,
+		Error:     msg,
+		RequestID: requestID,
 	})
 }
 
@@ -25,7 +30,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("Couldn't marshal JSON", "error", err)
 		w.WriteHeader(500)
 		return
 	}
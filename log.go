@@ -1,13 +1,151 @@
 package main
 
 import (
-	"log"
+	"context"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const requestIDHeader = "X-Request-ID"
+
+// Incoming request IDs are only trusted if they look like an ID, so clients
+// can't inject arbitrary text into the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// sensitiveKeys are redacted wherever they show up as a log attribute, a
+// header or a query parameter.
+var sensitiveKeys = map[string]struct{}{
+	"authorization":     {},
+	"cookie":            {},
+	"set-cookie":        {},
+	"password":          {},
+	"current_password":  {},
+	"token":             {},
+	"refresh_token":     {},
+	"access_token":      {},
+	"client_secret":     {},
+	"code_verifier":     {},
+	"secret":            {},
+	"api_key":           {},
+	"x-polka-signature": {},
+}
+
+const redacted = "[REDACTED]"
+
+type requestIDKey struct{}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newLogger returns a JSON logger that redacts sensitive attributes and adds
+// the request and trace IDs to every line logged with a request's context.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})})
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, redacted)
+	}
+	if h, ok := a.Value.Any().(http.Header); ok {
+		return slog.Any(a.Key, redactHeader(h))
+	}
+	return a
+}
+
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for k := range out {
+		if _, ok := sensitiveKeys[strings.ToLower(k)]; ok {
+			out[k] = []string{redacted}
+		}
+	}
+	return out
+}
+
+func redactQuery(q url.Values) string {
+	for k := range q {
+		if _, ok := sensitiveKeys[strings.ToLower(k)]; ok {
+			q[k] = []string{redacted}
+		}
+	}
+	return q.Encode()
+}
+
+// middlewareLog gives every request an ID, taken from X-Request-ID when the
+// client or proxy sent a usable one, and logs the request once it's done.
+// The ID is echoed in the response header, where respondWithError also
+// picks it up.
 func middlewareLog(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		r = r.WithContext(ctx)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", r.Pattern,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", rec.bytes,
+			"remote_ip", clientIP(r),
+			"user_agent", r.UserAgent(),
+		}
+		if r.URL.RawQuery != "" {
+			attrs = append(attrs, "query", redactQuery(r.URL.Query()))
+		}
+		slog.InfoContext(ctx, "request", attrs...)
 	})
 }
+
+// fatal logs and exits, for errors during startup
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
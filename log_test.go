@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"chirpy/internal/auth"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRedactsSensitiveValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, slog.LevelInfo)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret-jwt")
+	header.Set("Content-Type", "application/json")
+	logger.Info("test", "password", "hunter2", "refresh_token", "secret-refresh", "headers", header)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "secret-refresh", "secret-jwt"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Log output contains %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "application/json") {
+		t.Errorf("Log output is missing a header that isn't sensitive: %s", out)
+	}
+}
+
+func TestMiddlewareLogRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(newLogger(&buf, slog.LevelInfo))
+
+	handler := middlewareLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, r, http.StatusBadRequest, "Bad chirp", errors.New("chirp too long"))
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{name: "propagated", incoming: "abc-123", kept: true},
+		{name: "generated", incoming: "", kept: false},
+		{name: "invalid replaced", incoming: "bad id\nforged line", kept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest("GET", "/api/chirps?token=secret-token", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(requestIDHeader)
+			if requestID == "" {
+				t.Fatal("Response has no request ID")
+			}
+			if tt.kept && requestID != tt.incoming {
+				t.Errorf("Request ID = %q, expected %q", requestID, tt.incoming)
+			}
+			if !tt.kept && requestID == tt.incoming {
+				t.Errorf("Request ID %q should have been replaced", requestID)
+			}
+
+			var body map[string]string
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body["request_id"] != requestID {
+				t.Errorf("Error body request_id = %q, expected %q", body["request_id"], requestID)
+			}
+
+			// Every log line carries the ID, and the query is redacted
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if !strings.Contains(line, requestID) {
+					t.Errorf("Log line is missing the request ID: %s", line)
+				}
+			}
+			if strings.Contains(buf.String(), "secret-token") {
+				t.Errorf("Log output contains the token: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestCheckPasswordRequestID(t *testing.T) {
+	cfg := &apiConfig{passwordPolicy: auth.PasswordPolicy{MinLength: 8, MaxLength: 128}}
+	handler := middlewareLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg.checkPassword(w, r, "ding")
+	}))
+
+	req := httptest.NewRequest("POST", "/api/users", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Status code = %d, expected 400", w.Code)
+	}
+	var body struct {
+		RequestID string `json:"request_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.RequestID != "abc-123" {
+		t.Errorf("Error body request_id = %q, expected %q", body.RequestID, "abc-123")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Accounts lock after this many consecutive failures, for
+	// lockoutBaseDuration doubled for every further failure.
+	lockoutThreshold    = 5
+	lockoutBaseDuration = time.Minute
+	lockoutMaxDuration  = time.Hour
+
+	// Failed attempts allowed from one IP per window, across all accounts
+	ipFailedLoginLimit  = 20
+	ipFailedLoginWindow = 10 * time.Minute
+
+	// Failed logins take at least this long so the response time doesn't
+	// reveal whether the email exists or the account is locked.
+	failedLoginMinDuration = 300 * time.Millisecond
+)
+
+var errIncorrectLogin = errors.New("incorrect email or password")
+
+type loginThrottledError struct {
+	retryAfter time.Duration
+}
+
+func (e *loginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.retryAfter)
+}
+
+// dummyPasswordHash is checked against when the email is unknown so that the
+// request costs the same as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := auth.HashPassword("chirpy-dummy-password")
+	if err != nil {
+		fatal("Couldn't hash dummy password", "error", err)
+	}
+	return hash
+})
+
+// verifyLogin checks an email and password pair, applying per-IP throttling
+// and per-account lockout. Unknown emails, wrong passwords and locked
+// accounts all fail with errIncorrectLogin.
+func (cfg *apiConfig) verifyLogin(r *http.Request, email, password string) (database.User, error) {
+	start := time.Now()
+	ip := clientIP(r)
+
+	failed, retryAfter := cfg.loginLimiter.count(ip)
+	if failed >= ipFailedLoginLimit {
+		return database.User{}, &loginThrottledError{retryAfter: retryAfter}
+	}
+
+	dbUser, err := cfg.checkCredentials(r, ip, email, password)
+	if errors.Is(err, errIncorrectLogin) {
+		cfg.loginLimiter.hit(ip)
+		time.Sleep(time.Until(start.Add(failedLoginMinDuration)))
+	}
+	return dbUser, err
+}
+
+func (cfg *apiConfig) checkCredentials(r *http.Request, ip, email, password string) (database.User, error) {
+	dbUser, err := cfg.store.GetUserByEmail(r.Context(), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		auth.CheckPasswordHash(password, dummyPasswordHash())
+		return database.User{}, errIncorrectLogin
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	dbFailed, err := cfg.store.GetFailedLogins(r.Context(), dbUser.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+	locked := err == nil && dbFailed.LockedUntil.Valid && time.Now().UTC().Before(dbFailed.LockedUntil.Time)
+
+	valid, err := auth.CheckPasswordHash(password, dbUser.HashedPassword)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	// Attempts while locked aren't counted, otherwise the lockout would keep
+	// growing for as long as someone keeps guessing
+	if locked {
+		return database.User{}, errIncorrectLogin
+	}
+
+	if !valid {
+		err = cfg.recordFailedLogin(r, ip, dbUser.ID)
+		if err != nil {
+			return database.User{}, err
+		}
+		return database.User{}, errIncorrectLogin
+	}
+
+	err = cfg.store.ClearFailedLogins(r.Context(), dbUser.ID)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	cfg.upgradePasswordHash(r, &dbUser, password)
+
+	return dbUser, nil
+}
+
+// upgradePasswordHash rehashes the password if its hash predates the current
+// argon2id parameters. This is the only time we see the plaintext, so cost
+// settings can be raised without forcing resets. Failing to upgrade doesn't
+// fail the login.
+func (cfg *apiConfig) upgradePasswordHash(r *http.Request, dbUser *database.User, password string) {
+	needsRehash, err := auth.PasswordNeedsRehash(dbUser.HashedPassword)
+	if err != nil || !needsRehash {
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't rehash password", "error", err)
+		return
+	}
+
+	err = cfg.store.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             dbUser.ID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't save rehashed password", "error", err)
+		return
+	}
+	dbUser.HashedPassword = hash
+}
+
+func (cfg *apiConfig) recordFailedLogin(r *http.Request, ip string, userID uuid.UUID) error {
+	dbFailed, err := cfg.store.RecordFailedLogin(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	if dbFailed.FailedCount < lockoutThreshold {
+		return nil
+	}
+
+	lockedUntil := time.Now().UTC().Add(lockoutDuration(int(dbFailed.FailedCount)))
+	err = cfg.store.LockAccount(r.Context(), database.LockAccountParams{
+		UserID:      userID,
+		LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.store.CreateAccountLockout(r.Context(), database.CreateAccountLockoutParams{
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		Ip:          ip,
+		FailedCount: dbFailed.FailedCount,
+		LockedUntil: lockedUntil,
+	})
+}
+
+// lockoutDuration doubles for every failure past the threshold, up to
+// lockoutMaxDuration.
+func lockoutDuration(failedCount int) time.Duration {
+	exponent := failedCount - lockoutThreshold
+	if exponent < 0 {
+		return 0
+	}
+
+	d := float64(lockoutBaseDuration) * math.Pow(2, float64(exponent))
+	if d > float64(lockoutMaxDuration) {
+		return lockoutMaxDuration
+	}
+	return time.Duration(d)
+}
+
+// loginResult labels a verifyLogin outcome for metrics
+func loginResult(err error) string {
+	var throttled *loginThrottledError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &throttled):
+		return "throttled"
+	case errors.Is(err, errIncorrectLogin):
+		return "failed"
+	default:
+		return "error"
+	}
+}
+
+// respondWithLoginError hides why a login failed, except for IP throttling
+// which says nothing about the account.
+func respondWithLoginError(w http.ResponseWriter, r *http.Request, err error) {
+	var throttled *loginThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.retryAfter.Seconds()))))
+		respondWithError(w, r, http.StatusTooManyRequests, "Too many failed login attempts", err)
+		return
+	}
+	if errors.Is(err, errIncorrectLogin) {
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect email or password", err)
+		return
+	}
+	respondWithError(w, r, http.StatusInternalServerError, "Couldn't log in", err)
+}
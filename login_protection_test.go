@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failedCount int
+		expected    time.Duration
+	}{
+		{failedCount: 1, expected: 0},
+		{failedCount: lockoutThreshold, expected: time.Minute},
+		{failedCount: lockoutThreshold + 1, expected: 2 * time.Minute},
+		{failedCount: lockoutThreshold + 3, expected: 8 * time.Minute},
+		{failedCount: lockoutThreshold + 20, expected: lockoutMaxDuration},
+	}
+
+	for _, tt := range tests {
+		got := lockoutDuration(tt.failedCount)
+		if got != tt.expected {
+			t.Errorf("lockoutDuration(%d) = %v, expected %v", tt.failedCount, got, tt.expected)
+		}
+	}
+}
@@ -1,100 +1,240 @@
 package main
 
 import (
+	"chirpy/internal/auth"
+	"chirpy/internal/config"
 	"chirpy/internal/database"
-	"database/sql"
-	"log"
+	"chirpy/internal/mailer"
+	"chirpy/internal/tracing"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
-const (
-	PORT        = "8080"
-	STATIC_PATH = "."
-)
-
 type apiConfig struct {
 	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	jwtSecret      string
-	polkaKey       string
+	// store backs the core API. db is the same Postgres queries, for the
+	// features only Postgres supports; it's nil with any other store.
+	store            database.Store
+	db               *database.Queries
+	platform         string
+	jwtSecret        string
+	polkaKeys        []string
+	mfaEncryptionKey string
+	mailer           mailer.Mailer
+	baseURL          string
+	adminKey         string
+	// accessTokenLifetime also applies to OAuth access tokens
+	accessTokenLifetime  time.Duration
+	refreshTokenLifetime time.Duration
+	loginLimiter         *rateLimiter
+	passwordPolicy       auth.PasswordPolicy
+	entitlements         map[string]Entitlements
+	chirpLimiter         *rateLimiter
+	resetLimiter         *rateLimiter
+	verificationLimiter  *rateLimiter
+	webhookClient        *http.Client
+	metrics              *metrics
+	shuttingDown         atomic.Bool
+	readinessChecks      []readinessCheck
+	// background tracks work handlers hand off after responding, so
+	// shutdown can wait for it
+	background sync.WaitGroup
 }
 
 func main() {
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
+
+	if len(os.Args) > 1 {
+		var run func(args []string) error
+		switch os.Args[1] {
+		case "migrate":
+			run = func(args []string) error { return runMigrate(args, os.Stdout) }
+		case "admin":
+			run = func(args []string) error { return runAdmin(args, os.Stdin, os.Stdout) }
+		}
+		if run != nil {
+			err := run(os.Args[2:])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
 	}
-	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
+
+	fs := flag.NewFlagSet("chirpy", flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the effective config, with secrets masked, and exit")
+	conf, err := config.Load(fs, os.Args[1:], os.Getenv)
+	if err == nil {
+		err = conf.Validate()
 	}
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET must be set")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid config:\n%v\n", err)
+		os.Exit(2)
 	}
-	polkaKey := os.Getenv("POLKA_KEY")
-	if polkaKey == "" {
-		log.Fatal("POLKA_KEY must be set")
+	if *printConfig {
+		conf.Print(os.Stdout)
+		return
 	}
 
-	dbConn, err := sql.Open("postgres", dbURL)
+	slog.SetDefault(newLogger(os.Stdout, conf.Log.Level))
+	slog.Info("Loaded config", "config", conf)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     conf.Tracing.Exporter,
+		ServiceName:  conf.Tracing.ServiceName,
+		OTLPEndpoint: conf.Tracing.OTLPEndpoint,
+	})
 	if err != nil {
-		log.Fatalf("Error opening database %s", err)
+		fatal("Couldn't set up tracing", "error", err)
+	}
+
+	auth.SetPasswordParams(&argon2id.Params{
+		Memory:      uint32(conf.Passwords.Argon2MemoryKiB),
+		Iterations:  uint32(conf.Passwords.Argon2Iterations),
+		Parallelism: uint8(conf.Passwords.Argon2Parallelism),
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	})
+
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength: conf.Passwords.MinLength,
+		MaxLength: conf.Passwords.MaxLength,
+	}
+	if path := conf.Passwords.BreachedFile; path != "" {
+		breached, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			fatal("Couldn't load breached passwords", "error", err)
+		}
+		passwordPolicy.Breached = breached
+	}
+
+	entitlements := defaultEntitlements
+	if path := conf.EntitlementsFile; path != "" {
+		entitlements, err = loadEntitlements(path)
+		if err != nil {
+			fatal("Couldn't load entitlements", "error", err)
+		}
+	}
+
+	dbConn, backend, err := openDatabase(conf.Database.URL)
+	if err != nil {
+		fatal("Couldn't open database", "error", err)
+	}
+
+	if conf.Database.MigrateOnStart {
+		provider, err := newMigrationProvider(dbConn, backend)
+		if err != nil {
+			fatal("Couldn't load migrations", "error", err)
+		}
+		results, err := migrateUp(context.Background(), provider)
+		for _, result := range results {
+			slog.Info("Migration", "result", result.String())
+		}
+		if err != nil {
+			fatal("Couldn't migrate the database", "error", err)
+		}
 	}
 
-	dbQueries := database.New(dbConn)
+	store, dbQueries := newStore(dbConn, backend)
+
+	schemaVersion, err := expectedSchemaVersion(schemaFS, migrationsDir[backend])
+	if err != nil {
+		fatal("Couldn't read embedded migrations", "error", err)
+	}
 
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		jwtSecret:      jwtSecret,
-		polkaKey:       polkaKey,
+		fileserverHits:   atomic.Int32{},
+		store:            store,
+		db:               dbQueries,
+		platform:         conf.Server.Platform,
+		jwtSecret:        conf.Auth.JWTSecret,
+		polkaKeys:        conf.Auth.PolkaKeys,
+		mfaEncryptionKey: conf.Auth.MFAEncryptionKey,
+		mailer: mailer.NewSMTPMailer(
+			conf.Mail.SMTPAddr,
+			conf.Mail.From,
+			conf.Mail.SMTPUsername,
+			conf.Mail.SMTPPassword,
+		),
+		baseURL:              strings.TrimSuffix(conf.Server.BaseURL, "/"),
+		adminKey:             conf.Auth.AdminKey,
+		accessTokenLifetime:  conf.Auth.AccessTokenLifetime,
+		refreshTokenLifetime: conf.Auth.RefreshTokenLifetime,
+		loginLimiter:         newRateLimiter(ipFailedLoginWindow),
+		passwordPolicy:       passwordPolicy,
+		entitlements:         entitlements,
+		chirpLimiter:         newRateLimiter(chirpRateWindow),
+		resetLimiter:         newRateLimiter(passwordResetWindow),
+		verificationLimiter:  newRateLimiter(emailVerificationResendWindow),
+		webhookClient:        newWebhookClient(),
+		metrics:              newMetrics(dbConn),
+		readinessChecks:      databaseChecks(dbConn, schemaVersion),
 	}
 
 	mux := http.NewServeMux()
 	fsHandler := apiCfg.middlewareMetricsInc(
 		http.StripPrefix(
 			"/app",
-			http.FileServer(http.Dir(STATIC_PATH)),
+			http.FileServer(http.Dir(conf.Server.StaticPath)),
 		))
 	mux.Handle("/app/", fsHandler)
 
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
+	apiCfg.registerRoutes(mux)
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerListChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerDetailChirp)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
-
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	s := &http.Server{
+		Addr:           ":" + strconv.Itoa(conf.Server.Port),
+		Handler:        middlewareTrace(mux, middlewareLog(apiCfg.metrics.instrument(mux))),
+		ReadTimeout:    conf.Server.ReadTimeout,
+		WriteTimeout:   conf.Server.WriteTimeout,
+		MaxHeaderBytes: 1 << 20,
+	}
 
-	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		fatal("Couldn't listen", "error", err)
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhooks)
+	// A second signal during shutdown kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Account deletion and outbound webhooks are only available with Postgres
+	var workers []func(context.Context)
+	if apiCfg.db != nil {
+		workers = append(workers, apiCfg.runAccountPurge, apiCfg.runWebhookDispatcher)
+	}
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	slog.Info("Serving", "static_path", conf.Server.StaticPath, "port", conf.Server.Port, "database", backend)
+	err = apiCfg.serve(ctx, s, ln, workers, conf.Server.ShutdownDelay, conf.Server.ShutdownTimeout)
 
-	s := &http.Server{
-		Addr:           ":" + PORT,
-		Handler:        mux,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Couldn't flush traces", "error", err)
 	}
+	cancel()
+	dbConn.Close()
 
-	log.Printf("Serving files from %s on port: %s\n", STATIC_PATH, PORT)
-	log.Fatal(s.ListenAndServe())
+	if err != nil {
+		fatal("Server stopped", "error", err)
+	}
 }
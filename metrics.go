@@ -1,8 +1,15 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, req *http.Request) {
@@ -24,3 +31,125 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// metrics holds the Prometheus collectors. They live in their own registry
+// rather than the global default, so each apiConfig (and each test server)
+// gets an independent set.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+
+	chirpsCreated     prometheus.Counter
+	logins            *prometheus.CounterVec
+	webhookEvents     *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+}
+
+// newMetrics registers the collectors. db may be nil, in which case
+// connection pool stats are left out.
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Password login attempts by result.",
+		}, []string{"result"}),
+		webhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_events_total",
+			Help: "Incoming webhook events processed, by source, event and status.",
+		}, []string{"source", "event", "status"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_delivery_attempts_total",
+			Help: "Outbound webhook delivery attempts by resulting status.",
+		}, []string{"status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.requestsInFlight,
+		m.chirpsCreated,
+		m.logins,
+		m.webhookEvents,
+		m.webhookDeliveries,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "chirpy"))
+	}
+	return m
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// instrument wraps the whole mux. Routes are labelled with the pattern the
+// mux matched rather than the path, which keeps IDs out of the labels; the
+// mux sets r.Pattern on the request it was given, so it's readable here once
+// the handler returns.
+func (m *metrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.requestsInFlight.Inc()
+		defer m.requestsInFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
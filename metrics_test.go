@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsInstrument(t *testing.T) {
+	m := newMetrics(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.Handle("GET /metrics", m.handler())
+	server := httptest.NewServer(m.instrument(mux))
+	defer server.Close()
+
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/nowhere"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := []string{
+		// IDs are folded into the route pattern
+		`chirpy_http_requests_total{code="404",method="GET",route="GET /api/chirps/{chirpID}"} 2`,
+		`chirpy_http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`chirpy_http_request_duration_seconds_count{method="GET",route="GET /api/chirps/{chirpID}"} 2`,
+		// The scrape itself is in flight
+		`chirpy_http_requests_in_flight 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("Metrics output is missing %q", line)
+		}
+	}
+}
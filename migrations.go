@@ -0,0 +1,185 @@
+package main
+
+import (
+	"chirpy/internal/config"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// schemaFS holds the goose migrations this binary was built with, for
+// every backend
+//
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var schemaFS embed.FS
+
+// migrationsDir is where each backend's migrations are in schemaFS
+var migrationsDir = map[string]string{
+	backendPostgres: "sql/schema",
+	backendSQLite:   "sql/sqlite/schema",
+}
+
+// expectedSchemaVersion is the version of the newest migration in dir,
+// taken from its numeric file name prefix.
+func expectedSchemaVersion(fsys fs.FS, dir string) (int64, error) {
+	names, err := fs.Glob(fsys, dir+"/*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, name := range names {
+		base := name[strings.LastIndex(name, "/")+1:]
+		prefix, _, ok := strings.Cut(base, "_")
+		if !ok {
+			return 0, fmt.Errorf("migration %s has no version prefix", base)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has no version prefix", base)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// currentSchemaVersion reads the version goose last migrated the database
+// to. A version whose newest row isn't applied has been rolled back. The
+// query works on every backend.
+func currentSchemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRowContext(ctx, `
+SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version latest
+WHERE is_applied AND id = (
+	SELECT MAX(id) FROM goose_db_version WHERE version_id = latest.version_id
+)`).Scan(&version)
+	return version, err
+}
+
+// newMigrationProvider runs the embedded migrations for backend against db.
+// A Postgres advisory lock stops two instances migrating at the same time.
+// SQLite only has one instance.
+func newMigrationProvider(db *sql.DB, backend string) (*goose.Provider, error) {
+	fsys, err := fs.Sub(schemaFS, migrationsDir[backend])
+	if err != nil {
+		return nil, err
+	}
+	if backend == backendSQLite {
+		return goose.NewProvider(goose.DialectSQLite3, db, fsys)
+	}
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+}
+
+// migrateUp applies every pending migration. If one fails, the results
+// still cover the ones applied before it and the failure.
+func migrateUp(ctx context.Context, provider *goose.Provider) ([]*goose.MigrationResult, error) {
+	results, err := provider.Up(ctx)
+	var partialErr *goose.PartialError
+	if errors.As(err, &partialErr) {
+		results = append(partialErr.Applied, partialErr.Failed)
+	}
+	return results, err
+}
+
+const migrateUsage = `Usage: chirpy migrate [flags] up|down|status|version
+
+  up       apply every pending migration
+  down     roll back the latest migration
+  status   list every migration and whether it has been applied
+  version  print the version the database is migrated to
+
+Only database.url is required. It can be a Postgres or sqlite: URL. Flags:
+`
+
+// runMigrate is the "chirpy migrate" subcommand.
+func runMigrate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("chirpy migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	conf, err := config.Load(fs, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if conf.Database.URL == "" {
+		return errors.New("database.url must be set")
+	}
+
+	db, backend, err := openDatabase(conf.Database.URL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	provider, err := newMigrationProvider(db, backend)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		results, err := migrateUp(ctx, provider)
+		for _, result := range results {
+			fmt.Fprintln(out, result)
+		}
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(out, "No migrations to run")
+		}
+		return err
+	case "down":
+		result, err := provider.Down(ctx)
+		if result != nil {
+			fmt.Fprintln(out, result)
+		}
+		return err
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+		for _, status := range statuses {
+			appliedAt := ""
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		return w.Flush()
+	case "version":
+		version, err := provider.GetDBVersion(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, version)
+		return nil
+	default:
+		fs.Usage()
+		os.Exit(2)
+		return nil
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookEventChirpCreated   = "chirp.created"
+	webhookEventChirpUpdated   = "chirp.updated"
+	webhookEventChirpDeleted   = "chirp.deleted"
+	webhookEventChirpMentioned = "chirp.mentioned"
+)
+
+var validWebhookEvents = []string{
+	webhookEventChirpCreated,
+	webhookEventChirpUpdated,
+	webhookEventChirpDeleted,
+	webhookEventChirpMentioned,
+}
+
+// maxChirpMentions bounds the user lookups a single chirp can cause
+const maxChirpMentions = 10
+
+const (
+	webhookDeliveryPending   = "pending"
+	webhookDeliveryDelivered = "delivered"
+	webhookDeliveryDead      = "dead"
+
+	webhookDispatchInterval = 5 * time.Second
+	webhookDispatchBatch    = 20
+	webhookDeliveryTimeout  = 10 * time.Second
+
+	// A delivery is retried with exponential backoff and dead-lettered after
+	// webhookMaxAttempts, about a day and a half after the first try.
+	webhookMaxAttempts  = 15
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookMaxEndpoints = 10
+)
+
+const (
+	webhookEventHeader     = "X-Chirpy-Event"
+	webhookDeliveryHeader  = "X-Chirpy-Delivery"
+	webhookTimestampHeader = "X-Chirpy-Timestamp"
+	webhookSignatureHeader = "X-Chirpy-Signature"
+)
+
+var errPrivateWebhookTarget = errors.New("webhook URL resolves to a non-public address")
+
+type outboundWebhookPayload struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// enqueueWebhookEvent writes a delivery to the outbox for each of the user's
+// endpoints subscribed to event. Sending happens later in the dispatcher, so
+// a slow or broken endpoint never holds up the request that caused the
+// event. Failures are logged rather than failing that request.
+func (cfg *apiConfig) enqueueWebhookEvent(ctx context.Context, userID uuid.UUID, event string, data any) {
+	// Webhook endpoints are only stored in Postgres
+	if cfg.db == nil {
+		return
+	}
+
+	endpoints, err := cfg.db.ListWebhookEndpointsForEvent(ctx, database.ListWebhookEndpointsForEventParams{
+		UserID: userID,
+		Event:  event,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't list webhook endpoints", "event", event, "error", err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(outboundWebhookPayload{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't encode webhook", "event", event, "error", err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		_, err := cfg.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			Event:      event,
+			Payload:    payload,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Couldn't queue webhook", "event", event, "endpoint_id", endpoint.ID, "error", err)
+		}
+	}
+}
+
+// chirpMentions finds the users a chirp mentions. Users don't have handles,
+// so a mention is "@" followed by their email, e.g. "@walt@example.com".
+// Emails are normalized and returned once each, up to maxChirpMentions.
+func chirpMentions(body string) []string {
+	var emails []string
+	for _, word := range strings.Fields(body) {
+		rest, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		email, err := mailer.NormalizeAddress(strings.TrimRight(rest, ".,:;!?)"))
+		if err != nil || slices.Contains(emails, email) {
+			continue
+		}
+		emails = append(emails, email)
+		if len(emails) == maxChirpMentions {
+			break
+		}
+	}
+	return emails
+}
+
+// enqueueMentionEvents sends chirp.mentioned to each user the chirp
+// mentions, other than its author. Mentions of emails that aren't
+// registered are ignored.
+func (cfg *apiConfig) enqueueMentionEvents(ctx context.Context, chirp *Chirp) {
+	if cfg.db == nil {
+		return
+	}
+
+	for _, email := range chirpMentions(chirp.Body) {
+		user, err := cfg.store.GetUserByEmail(ctx, email)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Couldn't look up mentioned user", "error", err)
+			continue
+		}
+		if user.ID == chirp.UserID {
+			continue
+		}
+		cfg.enqueueWebhookEvent(ctx, user.ID, webhookEventChirpMentioned, chirp)
+	}
+}
+
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(webhookDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		err := cfg.dispatchWebhooks(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Couldn't dispatch webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchWebhooks sends one batch of due deliveries. Claiming a delivery
+// pushes its next attempt back, so several instances can dispatch at once and
+// a delivery claimed by an instance that dies is picked up again later.
+func (cfg *apiConfig) dispatchWebhooks(ctx context.Context) error {
+	deliveries, err := cfg.db.ClaimDueWebhookDeliveries(ctx, webhookDispatchBatch)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		err = cfg.deliverWebhook(ctx, &deliveries[i])
+		if err != nil {
+			return fmt.Errorf("delivering %s: %w", deliveries[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, d *database.WebhookDelivery) error {
+	endpoint, err := cfg.db.GetWebhookEndpoint(ctx, d.EndpointID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted since it was claimed, and the delivery went with it
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code, sendErr := cfg.sendWebhook(ctx, &endpoint, d)
+
+	attempts := int(d.Attempts) + 1
+	params := database.RecordWebhookDeliveryAttemptParams{
+		ID:            d.ID,
+		Status:        webhookDeliveryPending,
+		NextAttemptAt: time.Now().UTC().Add(webhookRetryDelay(attempts)),
+		ResponseCode:  sql.NullInt32{Int32: int32(code), Valid: code != 0},
+	}
+	switch {
+	case sendErr == nil:
+		params.Status = webhookDeliveryDelivered
+	case attempts >= webhookMaxAttempts:
+		params.Status = webhookDeliveryDead
+	}
+	if sendErr != nil {
+		params.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+
+	cfg.metrics.webhookDeliveries.WithLabelValues(params.Status).Inc()
+
+	return cfg.db.RecordWebhookDeliveryAttempt(ctx, params)
+}
+
+// sendWebhook posts the payload signed the same way as Polka's webhooks to
+// us, so receivers can reuse the scheme: v1=HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the endpoint's secret.
+func (cfg *apiConfig) sendWebhook(ctx context.Context, endpoint *database.WebhookEndpoint, d *database.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookDeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(webhookEventHeader, d.Event)
+	req.Header.Set(webhookDeliveryHeader, d.ID.String())
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(webhookSignatureHeader, auth.SignWebhook(endpoint.Secret, now, d.Payload))
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookRetryDelay is how long to wait after the given number of failed
+// attempts.
+func webhookRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	d := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return d
+}
+
+// newWebhookClient returns a client that refuses to connect to loopback,
+// private and link-local addresses, so user-supplied URLs can't be used to
+// reach internal services. The check runs on the resolved address, which
+// also covers hostnames that resolve to one. Redirects aren't followed for
+// the same reason.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			addr := addrPort.Addr().Unmap()
+			if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+				return errPrivateWebhookTarget
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   webhookDeliveryTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
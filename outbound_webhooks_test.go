@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: webhookBaseBackoff},
+		{attempts: 2, expected: 2 * webhookBaseBackoff},
+		{attempts: 4, expected: 8 * webhookBaseBackoff},
+		{attempts: webhookMaxAttempts, expected: webhookMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		got := webhookRetryDelay(tt.attempts)
+		if got != tt.expected {
+			t.Errorf("webhookRetryDelay(%d) = %v, expected %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+func TestChirpMentions(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected []string
+	}{
+		{body: "No mentions here", expected: nil},
+		{body: "Say my name, @Heisenberg@BreakingBad.com!", expected: []string{"heisenberg@breakingbad.com"}},
+		{body: "@jesse@example.com and @jesse@example.com again", expected: []string{"jesse@example.com"}},
+		{body: "Email walt@example.com or @saul", expected: nil},
+	}
+
+	for _, tt := range tests {
+		got := chirpMentions(tt.body)
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("chirpMentions(%q) = %v, expected %v", tt.body, got, tt.expected)
+		}
+	}
+
+	var many []string
+	for i := range maxChirpMentions + 5 {
+		many = append(many, "@user"+strconv.Itoa(i)+"@example.com")
+	}
+	if got := chirpMentions(strings.Join(many, " ")); len(got) != maxChirpMentions {
+		t.Errorf("chirpMentions() returned %d mentions, expected %d", len(got), maxChirpMentions)
+	}
+}
+
+func TestWebhookClientRejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request reached a loopback server")
+	}))
+	defer server.Close()
+
+	resp, err := newWebhookClient().Post(server.URL, "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("newWebhookClient() connected to a loopback address")
+	}
+	if !errors.Is(err, errPrivateWebhookTarget) {
+		t.Errorf("error = %v, expected errPrivateWebhookTarget", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"errors"
+	"net/http"
+)
+
+// checkPassword validates a new password against the policy, responding
+// with every violated rule if it fails.
+func (cfg *apiConfig) checkPassword(w http.ResponseWriter, r *http.Request, password string) bool {
+	type response struct {
+		Error      string                   `json:"error"`
+		RequestID  string                   `json:"request_id,omitempty"`
+		Violations []auth.PasswordViolation `json:"violations"`
+	}
+
+	err := cfg.passwordPolicy.Validate(password)
+	if err == nil {
+		return true
+	}
+
+	var policyErr *auth.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check password", err)
+		return false
+	}
+
+	respondWithJSON(w, http.StatusBadRequest, response{
+		Error:      "Password doesn't meet the password policy",
+		RequestID:  requestIDFromContext(r.Context()),
+		Violations: policyErr.Violations,
+	})
+	return false
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter counts hits per key in fixed windows. It's in-memory, so limits
+// apply per instance.
+type rateLimiter struct {
+	mu      sync.Mutex
+	window  time.Duration
+	windows map[string]*rateWindow
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// sweepThreshold bounds memory use when many distinct keys are seen
+const sweepThreshold = 10000
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		window:  window,
+		windows: map[string]*rateWindow{},
+		now:     time.Now,
+	}
+}
+
+// count returns the hits for key in the current window and how long until
+// the window resets.
+func (l *rateLimiter) count(key string) (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w := l.current(key)
+	return w.count, w.start.Add(l.window).Sub(l.now())
+}
+
+func (l *rateLimiter) hit(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.current(key).count++
+}
+
+// allow records a hit and reports true if key is still under limit, otherwise
+// it reports how long to wait.
+func (l *rateLimiter) allow(key string, limit int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w := l.current(key)
+	if w.count >= limit {
+		return false, w.start.Add(l.window).Sub(l.now())
+	}
+	w.count++
+	return true, 0
+}
+
+// current must be called with l.mu held.
+func (l *rateLimiter) current(key string) *rateWindow {
+	now := l.now()
+	w, ok := l.windows[key]
+	if ok && now.Sub(w.start) < l.window {
+		return w
+	}
+
+	if len(l.windows) >= sweepThreshold {
+		for k, old := range l.windows {
+			if now.Sub(old.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+	}
+
+	w = &rateWindow{start: now}
+	l.windows[key] = w
+	return w
+}
+
+// clientIP uses the connection's address rather than forwarding headers,
+// which clients can set to anything.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if ok, _ := l.allow("walt", 3); !ok {
+			t.Fatalf("allow() rejected hit %d under the limit", i+1)
+		}
+	}
+
+	ok, retryAfter := l.allow("walt", 3)
+	if ok {
+		t.Fatal("allow() accepted a hit over the limit")
+	}
+	if retryAfter != time.Minute {
+		t.Errorf("retryAfter = %v, expected %v", retryAfter, time.Minute)
+	}
+
+	// Other keys have their own windows
+	if ok, _ := l.allow("jesse", 3); !ok {
+		t.Error("allow() rejected a different key")
+	}
+
+	// The window resets
+	now = now.Add(time.Minute)
+	if ok, _ := l.allow("walt", 3); !ok {
+		t.Error("allow() rejected a hit after the window reset")
+	}
+}
+
+func TestRateLimiterCount(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.hit("walt")
+	now = now.Add(10 * time.Second)
+	l.hit("walt")
+
+	count, resetIn := l.count("walt")
+	if count != 2 {
+		t.Errorf("count = %d, expected 2", count)
+	}
+	if resetIn != 50*time.Second {
+		t.Errorf("resetIn = %v, expected %v", resetIn, 50*time.Second)
+	}
+}
@@ -1,9 +1,112 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
+const readinessTimeout = 2 * time.Second
+
+// readinessCheck is one dependency reported by /api/readyz. A passing check
+// can return a note worth showing without failing, such as a schema ahead of
+// this binary. Errors and notes are shown to anyone who can reach the
+// endpoint, so checks log the underlying cause and return a short
+// description.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) (string, error)
+}
+
+// databaseChecks verifies the database is reachable and migrated at least to
+// the version this binary expects. A newer schema only gets a note: during a
+// rolling deploy the first new instance migrates while old ones still serve,
+// and failing them all at once would leave nothing in rotation.
+func databaseChecks(db *sql.DB, expectedVersion int64) []readinessCheck {
+	return []readinessCheck{
+		{
+			name: "database",
+			check: func(ctx context.Context) (string, error) {
+				err := db.PingContext(ctx)
+				if err != nil {
+					slog.WarnContext(ctx, "Database ping failed", "error", err)
+					return "", errors.New("couldn't reach the database")
+				}
+				return "", nil
+			},
+		},
+		{
+			name: "migrations",
+			check: func(ctx context.Context) (string, error) {
+				version, err := currentSchemaVersion(ctx, db)
+				if err != nil {
+					slog.WarnContext(ctx, "Couldn't read the schema version", "error", err)
+					return "", errors.New("couldn't read the schema version")
+				}
+				if version < expectedVersion {
+					return "", fmt.Errorf("schema is at version %d, expected %d", version, expectedVersion)
+				}
+				if version > expectedVersion {
+					return fmt.Sprintf("schema is at version %d, ahead of %d", version, expectedVersion), nil
+				}
+				return "", nil
+			},
+		},
+	}
+}
+
+// handlerLiveness only reports that the process is up and serving, so the
+// orchestrator doesn't restart it over a database outage.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
+
+// handlerReadiness runs every dependency check and fails if any of them do.
+// It also fails once shutdown has started, so the load balancer stops
+// routing here while in-flight requests drain.
+func (cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	type checkResult struct {
+		Status string `json:"status"`
+		Note   string `json:"note,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+	type response struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}
+
+	if cfg.shuttingDown.Load() {
+		respondWithJSON(w, http.StatusServiceUnavailable, response{
+			Status: "shutting_down",
+			Checks: map[string]checkResult{},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	code := http.StatusOK
+	resp := response{
+		Status: "ok",
+		Checks: map[string]checkResult{},
+	}
+	for _, c := range cfg.readinessChecks {
+		note, err := c.check(ctx)
+		if err != nil {
+			resp.Checks[c.name] = checkResult{Status: "failing", Error: err.Error()}
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.name] = checkResult{Status: "ok", Note: note}
+	}
+
+	respondWithJSON(w, code, resp)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHandlerReadiness(t *testing.T) {
+	cfg := &apiConfig{
+		readinessChecks: []readinessCheck{
+			{name: "database", check: func(context.Context) (string, error) { return "", nil }},
+			{name: "migrations", check: func(context.Context) (string, error) { return "", errors.New("schema is at version 14, expected 15") }},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	cfg.handlerReadiness(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status = %d, expected 503", rec.Code)
+	}
+
+	var body struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Status != "unavailable" {
+		t.Errorf("status = %q, expected unavailable", body.Status)
+	}
+	if body.Checks["database"].Status != "ok" {
+		t.Errorf("database = %+v, expected ok", body.Checks["database"])
+	}
+	if body.Checks["migrations"].Status != "failing" || body.Checks["migrations"].Error == "" {
+		t.Errorf("migrations = %+v, expected failing with an error", body.Checks["migrations"])
+	}
+
+	cfg.readinessChecks = cfg.readinessChecks[:1]
+	rec = httptest.NewRecorder()
+	cfg.handlerReadiness(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status = %d with passing checks, expected 200", rec.Code)
+	}
+}
+
+// TestDatabaseChecksSchemaVersion only fails on a schema older than the
+// binary, so old instances stay ready while a new one migrates ahead of them
+func TestDatabaseChecksSchemaVersion(t *testing.T) {
+	db, backend, err := openDatabase("sqlite:" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite database: %v", err)
+	}
+	defer db.Close()
+	provider, err := newMigrationProvider(db, backend)
+	if err != nil {
+		t.Fatalf("Failed to create migration provider: %v", err)
+	}
+	if _, err := provider.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate SQLite database: %v", err)
+	}
+	version, err := currentSchemaVersion(context.Background(), db)
+	if err != nil {
+		t.Fatalf("currentSchemaVersion() returned an error: %v", err)
+	}
+
+	migrations := func(expected int64) (string, error) {
+		return databaseChecks(db, expected)[1].check(context.Background())
+	}
+	if note, err := migrations(version); note != "" || err != nil {
+		t.Errorf("Matching version: note %q, error %v, expected neither", note, err)
+	}
+	if note, err := migrations(version - 1); note == "" || err != nil {
+		t.Errorf("Schema ahead: note %q, error %v, expected a note and no error", note, err)
+	}
+	if _, err := migrations(version + 1); err == nil {
+		t.Error("Schema behind: expected an error")
+	}
+}
+
+func TestExpectedSchemaVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/schema/001_users.sql":  {},
+		"sql/schema/010_chirps.sql": {},
+		"sql/schema/002_tokens.sql": {},
+	}
+	version, err := expectedSchemaVersion(fsys, "sql/schema")
+	if err != nil {
+		t.Fatalf("expectedSchemaVersion() error: %v", err)
+	}
+	if version != 10 {
+		t.Errorf("version = %d, expected 10", version)
+	}
+
+	fsys["sql/schema/users.sql"] = &fstest.MapFile{}
+	if _, err := expectedSchemaVersion(fsys, "sql/schema"); err == nil {
+		t.Error("expectedSchemaVersion() accepted a migration without a version")
+	}
+
+	// The embedded migrations must all be numbered
+	for backend, dir := range migrationsDir {
+		if _, err := expectedSchemaVersion(schemaFS, dir); err != nil {
+			t.Errorf("Embedded %s migrations: %v", backend, err)
+		}
+	}
+}
@@ -10,9 +10,9 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg.fileserverHits.Store(0)
-	err := cfg.db.DeleteAllUsers(r.Context())
+	err := cfg.store.DeleteAllUsers(r.Context())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't delete users", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete users", err)
 		return
 	}
 
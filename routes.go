@@ -0,0 +1,71 @@
+package main
+
+import "net/http"
+
+// registerRoutes adds the API and admin routes to mux. Routes for the
+// features only Postgres supports are left out when cfg.db is nil.
+func (cfg *apiConfig) registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/livez", handlerLiveness)
+	// Deprecated alias of /api/livez, kept for probes set up before the split
+	mux.HandleFunc("GET /api/healthz", handlerLiveness)
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadiness)
+
+	mux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
+	mux.HandleFunc("GET /api/chirps", cfg.handlerListChirps)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerDetailChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.handlerUpdateChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirp)
+
+	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+
+	mux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
+	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
+	mux.HandleFunc("PATCH /api/users/me", cfg.handlerPatchUser)
+	mux.HandleFunc("GET /api/users/me/entitlements", cfg.handlerGetEntitlements)
+
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerPolkaWebhooks)
+
+	mux.Handle("GET /metrics", cfg.metrics.handler())
+	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
+	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+
+	if cfg.db == nil {
+		return
+	}
+
+	mux.HandleFunc("POST /api/login/mfa", cfg.handlerLoginMFA)
+	mux.HandleFunc("POST /api/password-reset/request", cfg.handlerRequestPasswordReset)
+	mux.HandleFunc("POST /api/password-reset/confirm", cfg.handlerConfirmPasswordReset)
+
+	mux.HandleFunc("DELETE /api/users/me", cfg.handlerDeleteUser)
+	mux.HandleFunc("POST /api/users/me/cancel-deletion", cfg.handlerCancelUserDeletion)
+	mux.HandleFunc("GET /api/users/me/export", cfg.handlerExportUser)
+	mux.HandleFunc("GET /api/users/verify-email", cfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/users/verify-email/resend", cfg.handlerResendEmailVerification)
+	mux.HandleFunc("POST /api/users/mfa", cfg.handlerEnrollMFA)
+	mux.HandleFunc("POST /api/users/mfa/confirm", cfg.handlerConfirmMFA)
+	mux.HandleFunc("DELETE /api/users/mfa", cfg.handlerDisableMFA)
+
+	mux.HandleFunc("POST /api/api_keys", cfg.handlerCreateAPIKey)
+	mux.HandleFunc("GET /api/api_keys", cfg.handlerListAPIKeys)
+	mux.HandleFunc("DELETE /api/api_keys/{keyID}", cfg.handlerRevokeAPIKey)
+
+	mux.HandleFunc("POST /api/webhooks", cfg.handlerCreateWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks", cfg.handlerListWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", cfg.handlerDeleteWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", cfg.handlerListWebhookDeliveries)
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/retry", cfg.handlerRetryWebhookDelivery)
+
+	mux.HandleFunc("POST /api/oauth/clients", cfg.handlerCreateOAuthClient)
+	mux.HandleFunc("GET /api/oauth/clients", cfg.handlerListOAuthClients)
+	mux.HandleFunc("GET /oauth/authorize", cfg.handlerOAuthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.handlerOAuthAuthorizeConsent)
+	mux.HandleFunc("POST /oauth/token", cfg.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", cfg.handlerOAuthRevoke)
+
+	mux.HandleFunc("GET /admin/webhooks", cfg.handlerListWebhookEvents)
+	mux.HandleFunc("GET /admin/webhooks/{eventID}", cfg.handlerGetWebhookEvent)
+	mux.HandleFunc("POST /admin/webhooks/{eventID}/replay", cfg.handlerReplayWebhookEvent)
+}
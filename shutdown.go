@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serve runs the server and the background workers until ctx is cancelled,
+// then shuts down in order: readiness starts failing so the load balancer
+// stops sending traffic, the listener closes once in-flight requests have
+// drained or timeout has passed, and finally the workers are stopped and
+// waited for. Closing the database is left to the caller, after serve
+// returns.
+func (cfg *apiConfig) serve(ctx context.Context, s *http.Server, ln net.Listener, workers []func(context.Context), delay, timeout time.Duration) error {
+	workerCtx, stopWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopWorkers()
+
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Go(func() { worker(workerCtx) })
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		stopWorkers()
+		wg.Wait()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "delay", delay, "timeout", timeout)
+	cfg.shuttingDown.Store(true)
+	time.Sleep(delay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := s.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Warn("Requests still running after the drain timeout", "error", err)
+		s.Close()
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Server stopped", "error", err)
+	}
+
+	// Let mail handed off by requests that have already been answered go
+	// out before the database is closed
+	cfg.background.Wait()
+
+	// A worker that is cancelled mid-batch leaves its claimed rows to be
+	// picked up again once their lease runs out.
+	stopWorkers()
+	wg.Wait()
+	slog.Info("Shut down")
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeShutdown(t *testing.T) {
+	cfg := &apiConfig{}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadiness)
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	url := "http://" + ln.Addr().String()
+
+	workerStopped := make(chan struct{})
+	worker := func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cfg.serve(ctx, &http.Server{Handler: mux}, ln, []func(context.Context){worker}, 200*time.Millisecond, 5*time.Second)
+	}()
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-started
+	cancel()
+
+	// Readiness fails during the delay, before the listener closes
+	time.Sleep(50 * time.Millisecond)
+	resp, err := http.Get(url + "/api/readyz")
+	if err != nil {
+		t.Fatalf("Failed to check readiness: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Readiness = %d during shutdown, expected 503", resp.StatusCode)
+	}
+
+	select {
+	case <-workerStopped:
+		t.Error("Worker stopped before requests drained")
+	default:
+	}
+
+	close(release)
+	if code := <-slow; code != http.StatusOK {
+		t.Errorf("In-flight request got %d, expected it to complete", code)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("serve() returned %v", err)
+	}
+
+	select {
+	case <-workerStopped:
+	default:
+		t.Error("serve() returned before stopping the worker")
+	}
+}
@@ -0,0 +1,83 @@
+-- name: DeleteChirpsForUser :exec
+DELETE FROM chirps
+WHERE
+	user_id = $1;
+
+-- name: DeleteRefreshTokensForUser :exec
+DELETE FROM refresh_tokens
+WHERE
+	user_id = $1
+	OR client_id IN (
+		SELECT
+			id
+		FROM
+			oauth_clients
+		WHERE
+			user_id = $1
+	);
+
+-- name: DeleteOAuthAuthorizationCodesForUser :exec
+DELETE FROM oauth_authorization_codes
+WHERE
+	user_id = $1
+	OR client_id IN (
+		SELECT
+			id
+		FROM
+			oauth_clients
+		WHERE
+			user_id = $1
+	);
+
+-- name: DeleteOAuthClientsForUser :exec
+DELETE FROM oauth_clients
+WHERE
+	user_id = $1;
+
+-- name: DeleteAPIKeysForUser :exec
+DELETE FROM api_keys
+WHERE
+	user_id = $1;
+
+-- name: DeleteSubscriptionEventsForUser :exec
+DELETE FROM subscription_events
+WHERE
+	user_id = $1;
+
+-- name: DeleteSubscriptionForUser :exec
+DELETE FROM subscriptions
+WHERE
+	user_id = $1;
+
+-- name: AnonymizeAccountLockoutsForUser :exec
+UPDATE account_lockouts
+SET
+	user_id = NULL,
+	ip = ''
+WHERE
+	user_id = $1;
+
+-- name: DeleteWebhookDeliveriesForUser :exec
+DELETE FROM webhook_deliveries
+WHERE
+	endpoint_id IN (
+		SELECT
+			id
+		FROM
+			webhook_endpoints
+		WHERE
+			user_id = $1
+	);
+
+-- name: DeleteWebhookEndpointsForUser :exec
+DELETE FROM webhook_endpoints
+WHERE
+	user_id = $1;
+
+-- Polka payloads name the user in data.user_id. The body is matched as bytes
+-- rather than parsed, so a payload that isn't valid jsonb can't fail a purge.
+-- name: DeleteWebhookEventsForUser :exec
+DELETE FROM webhook_events
+WHERE
+	source = 'polka'
+	AND position(convert_to(sqlc.arg(user_id)::uuid::text, 'UTF8') IN body) > 0;
@@ -0,0 +1,28 @@
+-- name: GetInstanceStats :one
+SELECT
+	(SELECT count(*) FROM users) AS users,
+	(SELECT count(*) FROM users WHERE email_verified_at IS NOT NULL) AS verified_users,
+	(SELECT count(*) FROM users WHERE deletion_requested_at IS NOT NULL) AS users_pending_deletion,
+	(
+		SELECT
+			count(*)
+		FROM
+			subscriptions
+		WHERE
+			status IN ('active', 'past_due', 'canceled')
+			AND current_period_end > now()
+	) AS subscribers,
+	(SELECT count(*) FROM chirps) AS chirps,
+	(SELECT count(*) FROM chirps WHERE created_at > now() - INTERVAL '24 hours') AS chirps_last_day,
+	(
+		SELECT
+			count(*)
+		FROM
+			refresh_tokens
+		WHERE
+			revoked_at IS NULL
+			AND expires_at > now()
+	) AS active_sessions,
+	(SELECT count(*) FROM webhook_endpoints) AS webhook_endpoints,
+	(SELECT count(*) FROM webhook_deliveries WHERE status = 'pending') AS pending_webhook_deliveries,
+	(SELECT count(*) FROM webhook_deliveries WHERE status = 'dead') AS dead_webhook_deliveries;
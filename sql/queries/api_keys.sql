@@ -0,0 +1,54 @@
+-- name: CreateAPIKey :one
+INSERT INTO
+	api_keys (
+		id,
+		created_at,
+		updated_at,
+		user_id,
+		name,
+		prefix,
+		hashed_key,
+		scopes,
+		expires_at
+	)
+VALUES
+	(gen_random_uuid(), now(), now(), $1, $2, $3, $4, $5, $6)
+RETURNING
+	*;
+
+-- name: ListAPIKeysForUser :many
+SELECT
+	*
+FROM
+	api_keys
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT
+	*
+FROM
+	api_keys
+WHERE
+	prefix = $1;
+
+-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET
+	updated_at = now(),
+	revoked_at = now()
+WHERE
+	id = $1
+	AND user_id = $2
+	AND revoked_at IS NULL
+RETURNING
+	*;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET
+	last_used_at = now()
+WHERE
+	id = $1;
@@ -26,3 +26,23 @@ WHERE
 DELETE FROM chirps
 WHERE
 	id = $1;
+
+-- name: ListChirpsForUser :many
+SELECT
+	*
+FROM
+	chirps
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC;
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET
+	updated_at = now(),
+	body = $2
+WHERE
+	id = $1
+RETURNING
+	*;
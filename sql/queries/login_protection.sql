@@ -0,0 +1,44 @@
+-- name: GetFailedLogins :one
+SELECT
+	*
+FROM
+	failed_logins
+WHERE
+	user_id = $1;
+
+-- name: RecordFailedLogin :one
+INSERT INTO
+	failed_logins (user_id, failed_count, last_failed_at)
+VALUES
+	($1, 1, now())
+ON CONFLICT (user_id) DO UPDATE
+SET
+	failed_count = failed_logins.failed_count + 1,
+	last_failed_at = now()
+RETURNING
+	*;
+
+-- name: LockAccount :exec
+UPDATE failed_logins
+SET
+	locked_until = $2
+WHERE
+	user_id = $1;
+
+-- name: ClearFailedLogins :exec
+DELETE FROM failed_logins
+WHERE
+	user_id = $1;
+
+-- name: CreateAccountLockout :exec
+INSERT INTO
+	account_lockouts (
+		id,
+		created_at,
+		user_id,
+		ip,
+		failed_count,
+		locked_until
+	)
+VALUES
+	(gen_random_uuid(), now(), $1, $2, $3, $4);
@@ -0,0 +1,88 @@
+-- name: StartUserMFAEnrollment :one
+INSERT INTO
+	user_mfa (user_id, created_at, updated_at, encrypted_secret)
+VALUES
+	($1, now(), now(), $2)
+ON CONFLICT (user_id) DO UPDATE
+SET
+	updated_at = now(),
+	encrypted_secret = EXCLUDED.encrypted_secret,
+	last_totp_step = NULL
+WHERE
+	user_mfa.enabled_at IS NULL
+RETURNING
+	*;
+
+-- name: GetUserMFA :one
+SELECT
+	*
+FROM
+	user_mfa
+WHERE
+	user_id = $1;
+
+-- name: EnableUserMFA :exec
+UPDATE user_mfa
+SET
+	updated_at = now(),
+	enabled_at = now()
+WHERE
+	user_id = $1;
+
+-- name: RecordTOTPStep :one
+UPDATE user_mfa
+SET
+	updated_at = now(),
+	last_totp_step = $2
+WHERE
+	user_id = $1
+	AND (
+		last_totp_step IS NULL
+		OR last_totp_step < $2
+	)
+RETURNING
+	user_id;
+
+-- name: DeleteUserMFA :exec
+DELETE FROM user_mfa
+WHERE
+	user_id = $1;
+
+-- name: CreateMFARecoveryCode :exec
+INSERT INTO
+	mfa_recovery_codes (id, created_at, user_id, code_hash)
+VALUES
+	(gen_random_uuid(), now(), $1, $2);
+
+-- name: UseMFARecoveryCode :one
+UPDATE mfa_recovery_codes
+SET
+	used_at = now()
+WHERE
+	user_id = $1
+	AND code_hash = $2
+	AND used_at IS NULL
+RETURNING
+	*;
+
+-- name: DeleteMFARecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE
+	user_id = $1;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO
+	mfa_challenges (token_hash, created_at, user_id, expires_at)
+VALUES
+	($1, now(), $2, $3);
+
+-- name: UseMFAChallenge :one
+UPDATE mfa_challenges
+SET
+	used_at = now()
+WHERE
+	token_hash = $1
+	AND used_at IS NULL
+	AND expires_at > now()
+RETURNING
+	*;
@@ -0,0 +1,115 @@
+-- name: CreateOAuthClient :one
+INSERT INTO
+	oauth_clients (
+		id,
+		created_at,
+		updated_at,
+		user_id,
+		name,
+		hashed_secret,
+		redirect_uris
+	)
+VALUES
+	($1, now(), now(), $2, $3, $4, $5)
+RETURNING
+	*;
+
+-- name: GetOAuthClient :one
+SELECT
+	*
+FROM
+	oauth_clients
+WHERE
+	id = $1;
+
+-- name: ListOAuthClientsForUser :many
+SELECT
+	*
+FROM
+	oauth_clients
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO
+	oauth_authorization_codes (
+		code_hash,
+		created_at,
+		client_id,
+		user_id,
+		redirect_uri,
+		code_challenge,
+		expires_at
+	)
+VALUES
+	($1, now(), $2, $3, $4, $5, $6);
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET
+	used_at = now()
+WHERE
+	code_hash = $1
+	AND used_at IS NULL
+	AND expires_at > now()
+RETURNING
+	*;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO
+	refresh_tokens (
+		token,
+		created_at,
+		updated_at,
+		user_id,
+		expires_at,
+		client_id
+	)
+VALUES
+	($1, now(), now(), $2, $3, $4)
+RETURNING
+	*;
+
+-- name: RotateOAuthRefreshToken :one
+UPDATE refresh_tokens
+SET
+	updated_at = now(),
+	revoked_at = now()
+WHERE
+	token = $1
+	AND client_id = $2
+	AND revoked_at IS NULL
+	AND expires_at > now()
+RETURNING
+	user_id;
+
+-- name: GetRevokedOAuthRefreshToken :one
+SELECT
+	*
+FROM
+	refresh_tokens
+WHERE
+	token = $1
+	AND client_id = $2
+	AND revoked_at IS NOT NULL;
+
+-- name: RevokeOAuthGrant :exec
+UPDATE refresh_tokens
+SET
+	updated_at = now(),
+	revoked_at = now()
+WHERE
+	user_id = $1
+	AND client_id = $2
+	AND revoked_at IS NULL;
+
+-- name: RevokeOAuthRefreshToken :exec
+UPDATE refresh_tokens
+SET
+	updated_at = now(),
+	revoked_at = now()
+WHERE
+	token = $1
+	AND client_id = $2;
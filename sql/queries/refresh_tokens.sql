@@ -20,6 +20,7 @@ SET
 	revoked_at = now()
 WHERE
 	token = $1
+	AND client_id IS NULL
 RETURNING
 	*;
 
@@ -31,5 +32,25 @@ FROM
 	JOIN refresh_tokens ON refresh_tokens.user_id = users.id
 WHERE
 	refresh_tokens.token = $1
+	AND refresh_tokens.client_id IS NULL
 	AND revoked_at IS NULL
 	AND expires_at > NOW();
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET
+	updated_at = now(),
+	revoked_at = now()
+WHERE
+	user_id = $1
+	AND revoked_at IS NULL;
+
+-- name: ListRefreshTokensForUser :many
+SELECT
+	*
+FROM
+	refresh_tokens
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC;
@@ -0,0 +1,89 @@
+-- name: GetSubscription :one
+SELECT
+	*
+FROM
+	subscriptions
+WHERE
+	user_id = $1;
+
+-- name: SetSubscription :one
+WITH
+	subscription AS (
+		INSERT INTO
+			subscriptions (
+				user_id,
+				created_at,
+				updated_at,
+				plan,
+				status,
+				current_period_start,
+				current_period_end
+			)
+		VALUES
+			(
+				sqlc.arg(user_id),
+				now(),
+				now(),
+				sqlc.arg(plan),
+				sqlc.arg(status),
+				sqlc.arg(current_period_start),
+				sqlc.arg(current_period_end)
+			)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+			updated_at = now(),
+			plan = excluded.plan,
+			status = excluded.status,
+			current_period_start = excluded.current_period_start,
+			current_period_end = excluded.current_period_end
+		RETURNING
+			*
+	),
+	history AS (
+		INSERT INTO
+			subscription_events (
+				id,
+				created_at,
+				user_id,
+				event,
+				plan,
+				status,
+				current_period_start,
+				current_period_end
+			)
+		SELECT
+			gen_random_uuid(),
+			now(),
+			user_id,
+			sqlc.arg(event)::text,
+			plan,
+			status,
+			current_period_start,
+			current_period_end
+		FROM
+			subscription
+	)
+SELECT
+	*
+FROM
+	subscription;
+
+-- name: ListSubscriptionEventsForUser :many
+SELECT
+	*
+FROM
+	subscription_events
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC;
+
+-- name: GetActivePlan :one
+SELECT
+	plan
+FROM
+	subscriptions
+WHERE
+	user_id = $1
+	AND status IN ('active', 'past_due', 'canceled')
+	AND current_period_end > now();
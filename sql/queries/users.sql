@@ -21,23 +21,81 @@ SELECT
 FROM
 	users
 WHERE
-	email = $1;
+	lower(email) = lower($1);
 
 -- name: UpdateUser :one
 UPDATE users
 SET
 	updated_at = now(),
 	email = $2,
-	hashed_password = $3
+	hashed_password = $3,
+	email_verified_at = CASE
+		WHEN email = $2 THEN email_verified_at
+		ELSE NULL
+	END
 WHERE
 	id = $1
 RETURNING
 	*;
 
--- name: UpgradeToChirpyRed :exec
+-- name: GetUserByID :one
+SELECT
+	*
+FROM
+	users
+WHERE
+	id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+	updated_at = now(),
+	hashed_password = $2
+WHERE
+	id = $1;
+
+-- name: VerifyUserEmail :one
 UPDATE users
 SET
 	updated_at = now(),
-	is_chirpy_red = TRUE
+	email_verified_at = now()
+WHERE
+	id = $1
+	AND email = $2
+RETURNING
+	*;
+
+-- name: RequestUserDeletion :one
+UPDATE users
+SET
+	updated_at = now(),
+	deletion_requested_at = now()
+WHERE
+	id = $1
+	AND deletion_requested_at IS NULL
+RETURNING
+	*;
+
+-- name: CancelUserDeletion :one
+UPDATE users
+SET
+	updated_at = now(),
+	deletion_requested_at = NULL
+WHERE
+	id = $1
+	AND deletion_requested_at IS NOT NULL
+RETURNING
+	*;
+
+-- name: ListUsersPendingDeletion :many
+SELECT
+	id
+FROM
+	users
+WHERE
+	deletion_requested_at < $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users
 WHERE
 	id = $1;
@@ -0,0 +1,135 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO
+	webhook_endpoints (id, created_at, updated_at, user_id, url, events, secret)
+VALUES
+	(gen_random_uuid(), now(), now(), $1, $2, $3, $4)
+RETURNING
+	*;
+
+-- name: ListWebhookEndpointsForUser :many
+SELECT
+	*
+FROM
+	webhook_endpoints
+WHERE
+	user_id = $1
+ORDER BY
+	created_at ASC;
+
+-- name: ListWebhookEndpointsForEvent :many
+SELECT
+	*
+FROM
+	webhook_endpoints
+WHERE
+	user_id = sqlc.arg(user_id)
+	AND sqlc.arg(event)::text = ANY (events);
+
+-- name: GetWebhookEndpoint :one
+SELECT
+	*
+FROM
+	webhook_endpoints
+WHERE
+	id = $1;
+
+-- name: GetWebhookEndpointForUser :one
+SELECT
+	*
+FROM
+	webhook_endpoints
+WHERE
+	id = $1
+	AND user_id = $2;
+
+-- name: DeleteWebhookEndpoint :one
+DELETE FROM webhook_endpoints
+WHERE
+	id = $1
+	AND user_id = $2
+RETURNING
+	*;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO
+	webhook_deliveries (
+		id,
+		created_at,
+		updated_at,
+		endpoint_id,
+		event,
+		payload,
+		status,
+		next_attempt_at
+	)
+VALUES
+	(gen_random_uuid(), now(), now(), $1, $2, $3, 'pending', now())
+RETURNING
+	*;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET
+	updated_at = now(),
+	next_attempt_at = now() + INTERVAL '5 minutes'
+WHERE
+	id IN (
+		SELECT
+			id
+		FROM
+			webhook_deliveries
+		WHERE
+			status = 'pending'
+			AND next_attempt_at <= now()
+		ORDER BY
+			next_attempt_at ASC
+		LIMIT
+			$1
+		FOR UPDATE
+			SKIP LOCKED
+	)
+RETURNING
+	*;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+UPDATE webhook_deliveries
+SET
+	updated_at = now(),
+	status = $2,
+	attempts = attempts + 1,
+	next_attempt_at = $3,
+	last_attempt_at = now(),
+	response_code = $4,
+	error = $5,
+	delivered_at = CASE
+		WHEN $2 = 'delivered' THEN now()
+		ELSE NULL
+	END
+WHERE
+	id = $1;
+
+-- name: ListWebhookDeliveriesForEndpoint :many
+SELECT
+	*
+FROM
+	webhook_deliveries
+WHERE
+	endpoint_id = $1
+ORDER BY
+	created_at DESC
+LIMIT
+	$2;
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET
+	updated_at = now(),
+	status = 'pending',
+	attempts = 0,
+	next_attempt_at = now()
+WHERE
+	id = $1
+	AND endpoint_id = $2
+	AND status = 'dead'
+RETURNING
+	*;
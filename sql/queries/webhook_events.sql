@@ -0,0 +1,78 @@
+-- name: ClaimWebhookEvent :one
+INSERT INTO
+	webhook_events (
+		id,
+		created_at,
+		updated_at,
+		source,
+		event,
+		headers,
+		body,
+		status,
+		attempts
+	)
+VALUES
+	($1, now(), now(), $2, $3, $4, $5, 'processing', 1)
+ON CONFLICT (id) DO UPDATE
+SET
+	updated_at = now(),
+	headers = excluded.headers,
+	body = excluded.body,
+	status = 'processing',
+	attempts = webhook_events.attempts + 1
+WHERE
+	webhook_events.status = 'failed'
+	OR (
+		webhook_events.status = 'processing'
+		AND webhook_events.updated_at < now() - INTERVAL '5 minutes'
+	)
+RETURNING
+	*;
+
+-- name: RetryWebhookEvent :one
+UPDATE webhook_events
+SET
+	updated_at = now(),
+	status = 'processing',
+	attempts = attempts + 1
+WHERE
+	id = $1
+	AND (
+		status <> 'processing'
+		OR updated_at < now() - INTERVAL '5 minutes'
+	)
+RETURNING
+	*;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET
+	updated_at = now(),
+	processed_at = now(),
+	status = $2,
+	response_code = $3,
+	error = $4,
+	processing_ms = $5
+WHERE
+	id = $1;
+
+-- name: GetWebhookEvent :one
+SELECT
+	*
+FROM
+	webhook_events
+WHERE
+	id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT
+	*
+FROM
+	webhook_events
+WHERE
+	sqlc.arg(status)::text = ''
+	OR status = sqlc.arg(status)
+ORDER BY
+	created_at DESC
+LIMIT
+	sqlc.arg(row_limit);
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE api_keys (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	prefix TEXT UNIQUE NOT NULL,
+	hashed_key TEXT NOT NULL,
+	scopes TEXT[] NOT NULL DEFAULT '{}',
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	hashed_secret TEXT,
+	redirect_uris TEXT[] NOT NULL
+);
+
+CREATE TABLE oauth_authorization_codes (
+	code_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	code_challenge TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+ALTER TABLE refresh_tokens
+ADD COLUMN client_id TEXT REFERENCES oauth_clients (id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN client_id;
+
+DROP TABLE oauth_authorization_codes;
+
+DROP TABLE oauth_clients;
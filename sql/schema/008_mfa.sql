@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE user_mfa (
+	user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	encrypted_secret TEXT NOT NULL,
+	enabled_at TIMESTAMP
+);
+
+CREATE TABLE mfa_recovery_codes (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE mfa_recovery_codes;
+
+DROP TABLE user_mfa;
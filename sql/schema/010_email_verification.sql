@@ -0,0 +1,28 @@
+-- +goose Up
+-- Emails are compared case-insensitively from now on. If two accounts only
+-- differ by case the unique index below fails and they must be merged by hand.
+UPDATE users
+SET
+	email = lower(trim(email));
+
+CREATE UNIQUE INDEX users_email_lower_idx ON users (lower(email));
+
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP;
+
+CREATE TABLE email_verification_tokens (
+	token_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	email TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE email_verification_tokens;
+
+ALTER TABLE users
+DROP COLUMN email_verified_at;
+
+DROP INDEX users_email_lower_idx;
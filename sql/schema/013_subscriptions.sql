@@ -0,0 +1,72 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+	user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_start TIMESTAMP NOT NULL,
+	current_period_end TIMESTAMP NOT NULL
+);
+
+CREATE TABLE subscription_events (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_start TIMESTAMP NOT NULL,
+	current_period_end TIMESTAMP NOT NULL
+);
+
+-- Red used to last until Polka downgraded the user, so existing members get
+-- a period that doesn't end. The first Polka event for them treats it as
+-- ending then (see legacyPeriodEnd).
+INSERT INTO
+	subscriptions (
+		user_id,
+		created_at,
+		updated_at,
+		plan,
+		status,
+		current_period_start,
+		current_period_end
+	)
+SELECT
+	id,
+	now(),
+	now(),
+	'chirpy_red',
+	'active',
+	now(),
+	TIMESTAMP '9999-12-31 00:00:00'
+FROM
+	users
+WHERE
+	is_chirpy_red;
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red boolean NOT NULL DEFAULT FALSE;
+
+UPDATE users
+SET
+	is_chirpy_red = TRUE
+WHERE
+	id IN (
+		SELECT
+			user_id
+		FROM
+			subscriptions
+		WHERE
+			status IN ('active', 'past_due', 'canceled')
+			AND current_period_end > now()
+	);
+
+DROP TABLE subscription_events;
+
+DROP TABLE subscriptions;
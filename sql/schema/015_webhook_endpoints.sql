@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	events TEXT[] NOT NULL DEFAULT '{}',
+	secret TEXT NOT NULL
+);
+
+CREATE INDEX webhook_endpoints_user_id_idx ON webhook_endpoints (user_id);
+
+CREATE TABLE webhook_deliveries (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	endpoint_id UUID NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	last_attempt_at TIMESTAMP,
+	response_code INTEGER,
+	error TEXT,
+	delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhook_endpoints;
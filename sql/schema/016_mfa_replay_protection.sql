@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE user_mfa
+ADD COLUMN last_totp_step BIGINT;
+
+CREATE TABLE mfa_challenges (
+	token_hash TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE mfa_challenges;
+
+ALTER TABLE user_mfa
+DROP COLUMN last_totp_step;
@@ -0,0 +1,54 @@
+-- name: CreateChirp :one
+INSERT INTO
+	chirps (id, created_at, updated_at, body, user_id)
+VALUES
+	(
+		sqlc.arg(id),
+		sqlc.arg(now),
+		sqlc.arg(now),
+		sqlc.arg(body),
+		sqlc.arg(user_id)
+	)
+RETURNING
+	*;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE
+	id = sqlc.arg(id);
+
+-- name: DetailChirp :one
+SELECT
+	*
+FROM
+	chirps
+WHERE
+	id = sqlc.arg(id);
+
+-- name: ListChirps :many
+SELECT
+	*
+FROM
+	chirps
+ORDER BY
+	created_at ASC;
+
+-- name: ListChirpsForUser :many
+SELECT
+	*
+FROM
+	chirps
+WHERE
+	user_id = sqlc.arg(user_id)
+ORDER BY
+	created_at ASC;
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET
+	updated_at = sqlc.arg(now),
+	body = sqlc.arg(body)
+WHERE
+	id = sqlc.arg(id)
+RETURNING
+	*;
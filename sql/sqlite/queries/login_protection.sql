@@ -0,0 +1,51 @@
+-- name: ClearFailedLogins :exec
+DELETE FROM failed_logins
+WHERE
+	user_id = sqlc.arg(user_id);
+
+-- name: CreateAccountLockout :exec
+INSERT INTO
+	account_lockouts (
+		id,
+		created_at,
+		user_id,
+		ip,
+		failed_count,
+		locked_until
+	)
+VALUES
+	(
+		sqlc.arg(id),
+		sqlc.arg(now),
+		sqlc.narg(user_id),
+		sqlc.arg(ip),
+		sqlc.arg(failed_count),
+		sqlc.arg(locked_until)
+	);
+
+-- name: GetFailedLogins :one
+SELECT
+	*
+FROM
+	failed_logins
+WHERE
+	user_id = sqlc.arg(user_id);
+
+-- name: LockAccount :exec
+UPDATE failed_logins
+SET
+	locked_until = sqlc.narg(locked_until)
+WHERE
+	user_id = sqlc.arg(user_id);
+
+-- name: RecordFailedLogin :one
+INSERT INTO
+	failed_logins (user_id, failed_count, last_failed_at)
+VALUES
+	(sqlc.arg(user_id), 1, sqlc.arg(now))
+ON CONFLICT (user_id) DO UPDATE
+SET
+	failed_count = failed_logins.failed_count + 1,
+	last_failed_at = excluded.last_failed_at
+RETURNING
+	*;
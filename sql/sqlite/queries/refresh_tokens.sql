@@ -0,0 +1,61 @@
+-- name: CreateRefreshToken :one
+INSERT INTO
+	refresh_tokens (
+		token,
+		created_at,
+		updated_at,
+		user_id,
+		expires_at,
+		revoked_at
+	)
+VALUES
+	(
+		sqlc.arg(token),
+		sqlc.arg(now),
+		sqlc.arg(now),
+		sqlc.arg(user_id),
+		sqlc.arg(expires_at),
+		sqlc.narg(revoked_at)
+	)
+RETURNING
+	*;
+
+-- name: GetUserFromRefreshToken :one
+SELECT
+	users.*
+FROM
+	users
+	JOIN refresh_tokens ON refresh_tokens.user_id = users.id
+WHERE
+	refresh_tokens.token = sqlc.arg(token)
+	AND refresh_tokens.revoked_at IS NULL
+	AND refresh_tokens.expires_at > sqlc.arg(now);
+
+-- name: ListRefreshTokensForUser :many
+SELECT
+	*
+FROM
+	refresh_tokens
+WHERE
+	user_id = sqlc.arg(user_id)
+ORDER BY
+	created_at ASC;
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens
+SET
+	updated_at = sqlc.arg(now),
+	revoked_at = sqlc.arg(now)
+WHERE
+	user_id = sqlc.arg(user_id)
+	AND revoked_at IS NULL;
+
+-- name: RevokeRefreshToken :one
+UPDATE refresh_tokens
+SET
+	updated_at = sqlc.arg(now),
+	revoked_at = sqlc.arg(now)
+WHERE
+	token = sqlc.arg(token)
+RETURNING
+	*;
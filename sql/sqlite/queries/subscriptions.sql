@@ -0,0 +1,72 @@
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO
+	subscription_events (
+		id,
+		created_at,
+		user_id,
+		event,
+		plan,
+		status,
+		current_period_start,
+		current_period_end
+	)
+VALUES
+	(
+		sqlc.arg(id),
+		sqlc.arg(now),
+		sqlc.arg(user_id),
+		sqlc.arg(event),
+		sqlc.arg(plan),
+		sqlc.arg(status),
+		sqlc.arg(current_period_start),
+		sqlc.arg(current_period_end)
+	);
+
+-- name: GetActivePlan :one
+SELECT
+	plan
+FROM
+	subscriptions
+WHERE
+	user_id = sqlc.arg(user_id)
+	AND status IN ('active', 'past_due', 'canceled')
+	AND current_period_end > sqlc.arg(now);
+
+-- name: GetSubscription :one
+SELECT
+	*
+FROM
+	subscriptions
+WHERE
+	user_id = sqlc.arg(user_id);
+
+-- name: UpsertSubscription :one
+INSERT INTO
+	subscriptions (
+		user_id,
+		created_at,
+		updated_at,
+		plan,
+		status,
+		current_period_start,
+		current_period_end
+	)
+VALUES
+	(
+		sqlc.arg(user_id),
+		sqlc.arg(now),
+		sqlc.arg(now),
+		sqlc.arg(plan),
+		sqlc.arg(status),
+		sqlc.arg(current_period_start),
+		sqlc.arg(current_period_end)
+	)
+ON CONFLICT (user_id) DO UPDATE
+SET
+	updated_at = excluded.updated_at,
+	plan = excluded.plan,
+	status = excluded.status,
+	current_period_start = excluded.current_period_start,
+	current_period_end = excluded.current_period_end
+RETURNING
+	*;
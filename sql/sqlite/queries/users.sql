@@ -0,0 +1,72 @@
+-- name: CreateUser :one
+INSERT INTO
+	users (
+		id,
+		created_at,
+		updated_at,
+		email,
+		hashed_password
+	)
+VALUES
+	(
+		sqlc.arg(id),
+		sqlc.arg(now),
+		sqlc.arg(now),
+		sqlc.arg(email),
+		sqlc.arg(hashed_password)
+	)
+RETURNING
+	*;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT
+	*
+FROM
+	users
+WHERE
+	lower(email) = lower(sqlc.arg(email));
+
+-- name: GetUserByID :one
+SELECT
+	*
+FROM
+	users
+WHERE
+	id = sqlc.arg(id);
+
+-- name: UpdateUser :one
+UPDATE users
+SET
+	updated_at = sqlc.arg(now),
+	email_verified_at = CASE
+		WHEN email = sqlc.arg(email) THEN email_verified_at
+		ELSE NULL
+	END,
+	email = sqlc.arg(email),
+	hashed_password = sqlc.arg(hashed_password)
+WHERE
+	id = sqlc.arg(id)
+RETURNING
+	*;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+	updated_at = sqlc.arg(now),
+	hashed_password = sqlc.arg(hashed_password)
+WHERE
+	id = sqlc.arg(id);
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET
+	updated_at = sqlc.arg(now),
+	email_verified_at = sqlc.arg(now)
+WHERE
+	id = sqlc.arg(id)
+	AND email = sqlc.arg(email)
+RETURNING
+	*;
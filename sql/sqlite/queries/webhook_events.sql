@@ -0,0 +1,52 @@
+-- name: ClaimWebhookEvent :one
+INSERT INTO
+	webhook_events (
+		id,
+		created_at,
+		updated_at,
+		source,
+		event,
+		headers,
+		body,
+		status,
+		attempts
+	)
+VALUES
+	(
+		sqlc.arg(id),
+		sqlc.arg(now),
+		sqlc.arg(now),
+		sqlc.arg(source),
+		sqlc.arg(event),
+		sqlc.arg(headers),
+		sqlc.arg(body),
+		'processing',
+		1
+	)
+ON CONFLICT (id) DO UPDATE
+SET
+	updated_at = excluded.updated_at,
+	headers = excluded.headers,
+	body = excluded.body,
+	status = 'processing',
+	attempts = webhook_events.attempts + 1
+WHERE
+	webhook_events.status = 'failed'
+	OR (
+		webhook_events.status = 'processing'
+		AND webhook_events.updated_at < sqlc.arg(stale_before)
+	)
+RETURNING
+	*;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET
+	updated_at = sqlc.arg(now),
+	processed_at = sqlc.arg(now),
+	status = sqlc.arg(status),
+	response_code = sqlc.narg(response_code),
+	error = sqlc.narg(error),
+	processing_ms = sqlc.narg(processing_ms)
+WHERE
+	id = sqlc.arg(id);
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	email TEXT NOT NULL,
+	hashed_password TEXT NOT NULL,
+	email_verified_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX users_email_lower_idx ON users (lower(email));
+
+-- +goose Down
+DROP TABLE users;
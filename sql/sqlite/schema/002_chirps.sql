@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE chirps (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	body TEXT NOT NULL,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirps_user_id_idx ON chirps (user_id);
+
+-- +goose Down
+DROP TABLE chirps;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE failed_logins (
+	user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	failed_count INTEGER NOT NULL,
+	last_failed_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP
+);
+
+CREATE TABLE account_lockouts (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id TEXT REFERENCES users (id) ON DELETE SET NULL,
+	ip TEXT NOT NULL,
+	failed_count INTEGER NOT NULL,
+	locked_until TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE account_lockouts;
+
+DROP TABLE failed_logins;
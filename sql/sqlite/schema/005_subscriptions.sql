@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+	user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_start TIMESTAMP NOT NULL,
+	current_period_end TIMESTAMP NOT NULL
+);
+
+CREATE TABLE subscription_events (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_start TIMESTAMP NOT NULL,
+	current_period_end TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE subscription_events;
+
+DROP TABLE subscriptions;
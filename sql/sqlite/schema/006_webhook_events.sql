@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+	id TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	source TEXT NOT NULL,
+	event TEXT NOT NULL,
+	headers TEXT NOT NULL,
+	body BLOB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	response_code INTEGER,
+	error TEXT,
+	processing_ms INTEGER,
+	processed_at TIMESTAMP
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, created_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"chirpy/internal/sqlitedb"
+	"chirpy/internal/sqlitestore"
+	"chirpy/internal/tracing"
+	"database/sql"
+	"strings"
+)
+
+const (
+	backendPostgres = "postgres"
+	backendSQLite   = "sqlite"
+)
+
+// databaseBackend picks the backend from the database URL. sqlite: URLs
+// use SQLite and anything else is handed to the Postgres driver, which also
+// takes key=value connection strings.
+func databaseBackend(dbURL string) string {
+	if strings.HasPrefix(dbURL, "sqlite:") {
+		return backendSQLite
+	}
+	return backendPostgres
+}
+
+// openDatabase opens dbURL with the driver for its backend
+func openDatabase(dbURL string) (*sql.DB, string, error) {
+	backend := databaseBackend(dbURL)
+	var db *sql.DB
+	var err error
+	switch backend {
+	case backendSQLite:
+		db, err = sqlitestore.Open(dbURL)
+	default:
+		db, err = sql.Open("postgres", dbURL)
+	}
+	return db, backend, err
+}
+
+// newStore returns the store for the core API. The Postgres queries are
+// also returned for the features only Postgres supports; they're nil with
+// any other backend.
+func newStore(db *sql.DB, backend string) (database.Store, *database.Queries) {
+	switch backend {
+	case backendSQLite:
+		return sqlitestore.New(db, func(db sqlitedb.DBTX) sqlitedb.DBTX {
+			return tracing.WrapDB(db, "sqlite")
+		}), nil
+	default:
+		queries := database.New(tracing.WrapDB(db, "postgresql"))
+		return queries, queries
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	planChirpyRed = "chirpy_red"
+
+	// Red lasts while the status is active, past_due or canceled and the
+	// current period hasn't ended; refunded, or revoked by an admin, ends it
+	// at once.
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionRefunded = "refunded"
+	subscriptionRevoked  = "revoked"
+
+	// Used when Polka doesn't say when the period ends
+	subscriptionPeriod = 30 * 24 * time.Hour
+	// Red is kept this long after a failed payment while Polka retries
+	paymentGracePeriod = 3 * 24 * time.Hour
+)
+
+const (
+	polkaEventUpgraded      = "user.upgraded"
+	polkaEventDowngraded    = "user.downgraded"
+	polkaEventRenewed       = "subscription.renewed"
+	polkaEventPaymentFailed = "subscription.payment_failed"
+	polkaEventRefunded      = "subscription.refunded"
+)
+
+// legacyPeriodEnd is the period end migration 013 gave Red members from
+// before subscriptions were tracked. Their Red lasts until Polka sends an
+// event for them.
+var legacyPeriodEnd = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type polkaEventData struct {
+	UserID           uuid.UUID  `json:"user_id"`
+	Plan             string     `json:"plan"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+}
+
+// nextSubscription works out the subscription after a Polka event, given the
+// current one if there is one. It reports false for events that don't
+// change anything, including lifecycle events for users who never
+// subscribed.
+func nextSubscription(event string, current *database.Subscription, data polkaEventData, now time.Time) (database.SetSubscriptionParams, bool) {
+	next := database.SetSubscriptionParams{
+		UserID: data.UserID,
+		Plan:   data.Plan,
+		Event:  event,
+	}
+	if next.Plan == "" {
+		next.Plan = planChirpyRed
+	}
+	periodEnd := func(start time.Time) time.Time {
+		if data.CurrentPeriodEnd != nil {
+			return data.CurrentPeriodEnd.UTC()
+		}
+		return start.Add(subscriptionPeriod)
+	}
+
+	if event == polkaEventUpgraded {
+		next.Status = subscriptionActive
+		next.CurrentPeriodStart = now
+		next.CurrentPeriodEnd = periodEnd(now)
+		return next, true
+	}
+
+	if current == nil {
+		return next, false
+	}
+	if data.Plan == "" {
+		next.Plan = current.Plan
+	}
+	next.CurrentPeriodStart = current.CurrentPeriodStart
+	next.CurrentPeriodEnd = current.CurrentPeriodEnd
+	// A legacy period was never paid up to its end, so the first event
+	// treats it as ending now, as Red without subscriptions did
+	if !current.CurrentPeriodEnd.Before(legacyPeriodEnd) {
+		next.CurrentPeriodEnd = now
+	}
+
+	switch event {
+	case polkaEventRenewed:
+		// Renewing early shouldn't cost the user the rest of their period
+		start := now
+		if next.CurrentPeriodEnd.After(now) {
+			start = next.CurrentPeriodEnd
+		}
+		next.Status = subscriptionActive
+		next.CurrentPeriodStart = start
+		next.CurrentPeriodEnd = periodEnd(start)
+	case polkaEventPaymentFailed:
+		next.Status = subscriptionPastDue
+		if grace := now.Add(paymentGracePeriod); next.CurrentPeriodEnd.Before(grace) {
+			next.CurrentPeriodEnd = grace
+		}
+	case polkaEventDowngraded:
+		// Already paid for, so Red lasts until the period ends
+		next.Status = subscriptionCanceled
+	case polkaEventRefunded:
+		next.Status = subscriptionRefunded
+		next.CurrentPeriodEnd = now
+	default:
+		return next, false
+	}
+
+	return next, true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNextSubscription(t *testing.T) {
+	now := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	data := polkaEventData{UserID: userID}
+	current := &database.Subscription{
+		UserID:             userID,
+		Plan:               planChirpyRed,
+		Status:             subscriptionActive,
+		CurrentPeriodStart: now.Add(-20 * 24 * time.Hour),
+		CurrentPeriodEnd:   now.Add(10 * 24 * time.Hour),
+	}
+	legacy := &database.Subscription{
+		UserID:             userID,
+		Plan:               planChirpyRed,
+		Status:             subscriptionActive,
+		CurrentPeriodStart: now.Add(-90 * 24 * time.Hour),
+		CurrentPeriodEnd:   legacyPeriodEnd,
+	}
+
+	tests := []struct {
+		name        string
+		event       string
+		current     *database.Subscription
+		expectedOK  bool
+		status      string
+		periodStart time.Time
+		periodEnd   time.Time
+	}{
+		{
+			name:        "upgrade starts a period",
+			event:       polkaEventUpgraded,
+			expectedOK:  true,
+			status:      subscriptionActive,
+			periodStart: now,
+			periodEnd:   now.Add(subscriptionPeriod),
+		},
+		{
+			name:        "early renewal keeps the rest of the period",
+			event:       polkaEventRenewed,
+			current:     current,
+			expectedOK:  true,
+			status:      subscriptionActive,
+			periodStart: current.CurrentPeriodEnd,
+			periodEnd:   current.CurrentPeriodEnd.Add(subscriptionPeriod),
+		},
+		{
+			name:        "failed payment keeps a paid period",
+			event:       polkaEventPaymentFailed,
+			current:     current,
+			expectedOK:  true,
+			status:      subscriptionPastDue,
+			periodStart: current.CurrentPeriodStart,
+			periodEnd:   current.CurrentPeriodEnd,
+		},
+		{
+			name:        "downgrade lasts until the period ends",
+			event:       polkaEventDowngraded,
+			current:     current,
+			expectedOK:  true,
+			status:      subscriptionCanceled,
+			periodStart: current.CurrentPeriodStart,
+			periodEnd:   current.CurrentPeriodEnd,
+		},
+		{
+			name:        "refund ends the period now",
+			event:       polkaEventRefunded,
+			current:     current,
+			expectedOK:  true,
+			status:      subscriptionRefunded,
+			periodStart: current.CurrentPeriodStart,
+			periodEnd:   now,
+		},
+		{
+			name:        "renewal replaces a legacy period",
+			event:       polkaEventRenewed,
+			current:     legacy,
+			expectedOK:  true,
+			status:      subscriptionActive,
+			periodStart: now,
+			periodEnd:   now.Add(subscriptionPeriod),
+		},
+		{
+			name:        "downgrade ends a legacy period now",
+			event:       polkaEventDowngraded,
+			current:     legacy,
+			expectedOK:  true,
+			status:      subscriptionCanceled,
+			periodStart: legacy.CurrentPeriodStart,
+			periodEnd:   now,
+		},
+		{
+			name:       "lifecycle event without a subscription",
+			event:      polkaEventRenewed,
+			expectedOK: false,
+		},
+		{
+			name:       "unknown event",
+			event:      "user.renamed",
+			current:    current,
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, ok := nextSubscription(tt.event, tt.current, data, now)
+			if ok != tt.expectedOK {
+				t.Fatalf("ok = %v, expected %v", ok, tt.expectedOK)
+			}
+			if !ok {
+				return
+			}
+			if next.Status != tt.status {
+				t.Errorf("Status = %q, expected %q", next.Status, tt.status)
+			}
+			if !next.CurrentPeriodStart.Equal(tt.periodStart) {
+				t.Errorf("CurrentPeriodStart = %v, expected %v", next.CurrentPeriodStart, tt.periodStart)
+			}
+			if !next.CurrentPeriodEnd.Equal(tt.periodEnd) {
+				t.Errorf("CurrentPeriodEnd = %v, expected %v", next.CurrentPeriodEnd, tt.periodEnd)
+			}
+		})
+	}
+}
+
+// TestNextSubscriptionPaymentFailedGrace extends a lapsed period so Red
+// survives while Polka retries the payment.
+func TestNextSubscriptionPaymentFailedGrace(t *testing.T) {
+	now := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	current := &database.Subscription{
+		Plan:             planChirpyRed,
+		Status:           subscriptionActive,
+		CurrentPeriodEnd: now,
+	}
+
+	next, ok := nextSubscription(polkaEventPaymentFailed, current, polkaEventData{}, now)
+	if !ok {
+		t.Fatal("nextSubscription() ignored a failed payment")
+	}
+	if expected := now.Add(paymentGracePeriod); !next.CurrentPeriodEnd.Equal(expected) {
+		t.Errorf("CurrentPeriodEnd = %v, expected %v", next.CurrentPeriodEnd, expected)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const httpTracerName = "chirpy"
+
+// middlewareTrace starts a server span for every request, continuing the
+// trace from an incoming traceparent header. The span is named after the
+// route pattern. The mux sets r.Pattern on the request it's given, but that
+// is a copy made further down by WithContext, so it never reaches this
+// middleware's request; the pattern is looked up from mux up front instead.
+func middlewareTrace(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		_, route := mux.Handler(r)
+		name := route
+		if name == "" {
+			name = r.Method + " unmatched"
+		}
+
+		ctx, span := otel.Tracer(httpTracerName).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(clientIP(r)),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status > 499 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var handlerTraceID trace.TraceID
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTraceID = trace.SpanContextFromContext(r.Context()).TraceID()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(middlewareTrace(mux, mux))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/api/chirps/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/nowhere")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Recorded %d spans, expected 2", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "GET /api/chirps/{chirpID}" {
+		t.Errorf("Span name = %q, expected the route pattern", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Trace ID = %s, expected the incoming trace to be continued", got)
+	}
+	if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Parent span = %s, expected the incoming span", span.Parent().SpanID())
+	}
+	if handlerTraceID != span.SpanContext().TraceID() {
+		t.Error("Handler context doesn't carry the span")
+	}
+	if span.Status().Code != codes.Error {
+		t.Error("500 response isn't marked as an error")
+	}
+
+	if spans[1].Name() != "GET unmatched" {
+		t.Errorf("Span name = %q, expected GET unmatched", spans[1].Name())
+	}
+}